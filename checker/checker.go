@@ -2,6 +2,7 @@ package checker
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -9,7 +10,7 @@ import (
 	"github.com/mikkeloscar/maze/model"
 	"github.com/mikkeloscar/maze/remote"
 	"github.com/mikkeloscar/maze/repo"
-	"github.com/mikkeloscar/maze/source/aur"
+	"github.com/mikkeloscar/maze/source"
 	"github.com/mikkeloscar/maze/store"
 )
 
@@ -27,7 +28,33 @@ func (c *Checker) update(u *model.User, r *repo.Repo) error {
 		return err
 	}
 
-	updatePkgs, checkPkgs, err := aur.Updates(conf.AUR, r)
+	names := make([]string, 0, len(conf.Sources))
+	for name := range conf.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		src, ok := source.Get(name)
+		if !ok {
+			log.Warnf("unknown package source '%s' in packages.yml of %s/%s", name, r.SourceOwner, r.SourceName)
+			continue
+		}
+
+		err = c.updateSource(u, r, name, src, conf.Section(name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// trigger update builds for new packages of a source.
+func (c *Checker) updateSource(u *model.User, r *repo.Repo, name string, src source.Source, entries []string) error {
+	files := remote.NewFileReader(c.Remote, u, r.SourceOwner, r.SourceName)
+
+	updatePkgs, checkPkgs, err := src.Updates(entries, r, files)
 	if err != nil {
 		return err
 	}
@@ -42,7 +69,7 @@ func (c *Checker) update(u *model.User, r *repo.Repo) error {
 			r.SourceName,
 			r.SourceBranch,
 			r.BuildBranch,
-			fmt.Sprintf("update:%s:aur", strings.Join(pkgs, ",")),
+			fmt.Sprintf("update:%s:%s", strings.Join(pkgs, ","), name),
 		)
 		if err != nil {
 			return err
@@ -60,7 +87,7 @@ func (c *Checker) update(u *model.User, r *repo.Repo) error {
 			r.SourceName,
 			r.SourceBranch,
 			r.BuildBranch,
-			fmt.Sprintf("check:%s:aur", strings.Join(pkgs, ",")),
+			fmt.Sprintf("check:%s:%s", strings.Join(pkgs, ","), name),
 		)
 		if err != nil {
 			return err
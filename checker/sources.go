@@ -0,0 +1,8 @@
+package checker
+
+// Package sources checked for updates. Sources register themselves for the
+// packages.yml section named after them when imported.
+import (
+	_ "github.com/mikkeloscar/maze/source/aur"
+	_ "github.com/mikkeloscar/maze/source/local"
+)
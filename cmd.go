@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/store"
+)
+
+// runCommand runs a maze subcommand instead of the server.
+func runCommand(s store.Store, args []string) error {
+	switch args[0] {
+	case "fsck":
+		return fsck(s, args[1:])
+	case "rebuild":
+		return rebuild(s, args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// cmdRepos returns the repos given as owner/name arguments or all repos if
+// no arguments are given.
+func cmdRepos(s store.Store, args []string) ([]*model.Repo, error) {
+	if len(args) == 0 {
+		return s.Repos().GetRepoList()
+	}
+
+	repos := make([]*model.Repo, 0, len(args))
+	for _, arg := range args {
+		split := strings.Split(arg, "/")
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid repo format: %s", arg)
+		}
+
+		r, err := s.Repos().GetByName(split[0], split[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get repo '%s': %s", arg, err)
+		}
+		repos = append(repos, r)
+	}
+
+	return repos, nil
+}
+
+// fsck verifies the dbs of repos against the package files.
+//
+//	maze fsck [-repair] [owner/name...]
+func fsck(s store.Store, args []string) error {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "Repair the problems found.")
+	flags.Parse(args)
+
+	repos, err := cmdRepos(s, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	total := 0
+
+	for _, r := range repos {
+		problems, err := repo.NewRepo(r, repo.RepoStorage).Verify(*repair)
+		if err != nil {
+			return fmt.Errorf("failed to verify repo '%s/%s': %s", r.Owner, r.Name, err)
+		}
+
+		for _, problem := range problems {
+			fmt.Printf("%s/%s: %s\n", r.Owner, r.Name, problem)
+		}
+		total += len(problems)
+	}
+
+	if total > 0 && !*repair {
+		return fmt.Errorf("found %d problems, run with -repair to fix them", total)
+	}
+
+	return nil
+}
+
+// rebuild regenerates the dbs of repos from the package files.
+//
+//	maze rebuild [owner/name...]
+func rebuild(s store.Store, args []string) error {
+	repos, err := cmdRepos(s, args)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range repos {
+		results, err := repo.NewRepo(r, repo.RepoStorage).Rebuild()
+		if err != nil {
+			return fmt.Errorf("failed to rebuild repo '%s/%s': %s", r.Owner, r.Name, err)
+		}
+
+		for _, result := range results {
+			fmt.Printf("%s/%s: %s: %d packages, %d archived\n",
+				r.Owner, r.Name, result.Arch, len(result.Packages), len(result.Archived))
+			for _, file := range result.Invalid {
+				fmt.Printf("%s/%s: %s: skipped invalid file %s\n", r.Owner, r.Name, result.Arch, file)
+			}
+		}
+	}
+
+	return nil
+}
@@ -7,9 +7,15 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-// PkgConfig defines the packages to be build for a repository.
+// PkgConfig defines the packages to be build for a repository. Packages
+// are listed in sections named after their source e.g. "aur".
 type PkgConfig struct {
-	AUR []string `yaml:"aur"`
+	Sources map[string][]string `yaml:",inline"`
+}
+
+// Section returns the entries of a section.
+func (c *PkgConfig) Section(name string) []string {
+	return c.Sources[name]
 }
 
 // ReadConfig reads the content of an io.ReadCloser into a PkgConfig struct.
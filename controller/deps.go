@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	"github.com/mikkeloscar/maze/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// validLinkedRepos returns true if all linked repos exist, are readable by
+// the user and are different from the repo itself.
+func validLinkedRepos(c *gin.Context, user *model.User, owner, name string, linked []string) bool {
+	for _, l := range linked {
+		lOwner, lName, err := splitRepoName(l)
+		if err != nil {
+			return false
+		}
+
+		if lOwner == owner && lName == name {
+			return false
+		}
+
+		r, err := store.GetRepoByOwnerName(c, lOwner, lName)
+		if err != nil || !session.Perm(user, r).Read {
+			return false
+		}
+	}
+
+	return true
+}
+
+// linkedRepos gets the linked repos of a repo. Linked repos which no longer
+// exist are skipped.
+func linkedRepos(c *gin.Context, r *model.Repo) []*repo.Repo {
+	linked := make([]*repo.Repo, 0, len(r.LinkedRepos))
+
+	for _, l := range r.LinkedRepos {
+		owner, name, err := splitRepoName(l)
+		if err != nil {
+			continue
+		}
+
+		lr, err := store.GetRepoByOwnerName(c, owner, name)
+		if err != nil {
+			log.Warnf("linked repo '%s' of repo '%s/%s' not found", l, r.Owner, r.Name)
+			continue
+		}
+
+		linked = append(linked, repo.NewRepo(lr, repo.RepoStorage))
+	}
+
+	return linked
+}
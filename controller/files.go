@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/common/util"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	"github.com/mikkeloscar/maze/store"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultFileResults = 100
+	maxFileResults     = 1000
+)
+
+// readableRepos returns the repos the current user can read.
+func readableRepos(c *gin.Context) ([]*repo.Repo, error) {
+	user := session.User(c)
+
+	repos, err := store.GetRepoList(c)
+	if err != nil {
+		return nil, err
+	}
+
+	readable := make([]*repo.Repo, 0, len(repos))
+	for _, r := range repos {
+		if session.Perm(user, r).Read {
+			readable = append(readable, repo.NewRepo(r, repo.RepoStorage))
+		}
+	}
+
+	return readable, nil
+}
+
+// GetFiles finds the packages owning files matching a query across all
+// readable repos. The query 'q' is matched according to 'mode' which is one
+// of 'path', 'name', 'glob' or 'regex'. The search can be limited to an
+// 'owner' and an 'arch'.
+func GetFiles(c *gin.Context) {
+	query, err := repo.NewFileQuery(c.Query("q"), c.Query("mode"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	limit, err := intQuery(c, "limit", defaultFileResults, maxFileResults)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	owner := c.Query("owner")
+	arch := c.Query("arch")
+
+	repos, err := readableRepos(c)
+	if err != nil {
+		log.Errorf("failed to get repos: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	results := []*model.FileOwner{}
+
+	for _, r := range repos {
+		if owner != "" && r.Owner != owner {
+			continue
+		}
+
+		if arch != "" && !util.StrContains(arch, r.Archs) {
+			continue
+		}
+
+		archs := r.Archs
+		if arch != "" {
+			archs = []string{arch}
+		}
+
+		for _, a := range archs {
+			owners, err := r.SearchFiles(a, query, limit-len(results))
+			if err != nil {
+				log.Errorf("failed to search files of repo '%s/%s': %s", r.Owner, r.Name, err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+
+			results = append(results, owners...)
+
+			if len(results) >= limit {
+				c.JSON(http.StatusOK, results)
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, results)
+}
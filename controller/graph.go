@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/common/util"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetRepoGraph returns the dependency graph of the packages of a repo arch.
+// The graph is returned as JSON or, with format=dot, in the Graphviz DOT
+// format.
+func GetRepoGraph(c *gin.Context) {
+	r := session.Repo(c)
+	arch := c.Param("arch")
+
+	if !util.StrContains(arch, r.Archs) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	graph, err := r.DepGraph(arch)
+	if err != nil {
+		log.Errorf("Failed to get dependency graph of repo '%s/%s': %s", r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	switch c.Query("format") {
+	case "", "json":
+		c.JSON(http.StatusOK, graph)
+	case "dot":
+		var buf bytes.Buffer
+		err = repo.WriteDOT(&buf, fmt.Sprintf("%s/%s/%s", r.Owner, r.Name, arch), graph)
+		if err != nil {
+			log.Errorf("Failed to write dependency graph: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", buf.Bytes())
+	default:
+		c.AbortWithStatus(http.StatusBadRequest)
+	}
+}
+
+// GetRepoPackageDeps returns the forward dependency tree of a package.
+func GetRepoPackageDeps(c *gin.Context) {
+	r := session.Repo(c)
+	pkgname := c.Param("package")
+	arch := c.Param("arch")
+
+	if !util.StrContains(arch, r.Archs) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	tree, err := r.DepTree(pkgname, arch)
+	if err != nil {
+		log.Errorf("Failed to get dependencies of package '%s': %s", pkgname, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if tree == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetRepoPackageReverseDeps returns the packages depending on a package.
+func GetRepoPackageReverseDeps(c *gin.Context) {
+	r := session.Repo(c)
+	pkgname := c.Param("package")
+	arch := c.Param("arch")
+
+	if !util.StrContains(arch, r.Archs) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	rdeps, err := r.ReverseDeps(pkgname, arch)
+	if err != nil {
+		log.Errorf("Failed to get reverse dependencies of package '%s': %s", pkgname, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if rdeps == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, rdeps)
+}
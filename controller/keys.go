@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	"github.com/mikkeloscar/maze/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetRepoKeys lists the trusted packager keys of a repo.
+func GetRepoKeys(c *gin.Context) {
+	r := session.Repo(c)
+
+	keys, err := store.GetRepoKeys(c, r.Repo)
+	if err != nil {
+		log.Errorf("failed to get keys of repo '%s/%s': %s", r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if keys == nil {
+		keys = []*model.Key{}
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// PostRepoKey adds one or more armored public keys to the trusted packager
+// keys of a repo.
+func PostRepoKey(c *gin.Context) {
+	r := session.Repo(c)
+
+	in := struct {
+		Key string `json:"key" binding:"required"`
+	}{}
+	err := c.BindJSON(&in)
+	if err != nil {
+		log.Errorf("failed to parse request body: %s", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	keys, err := repo.ParseKeys(in.Key)
+	if err != nil || len(keys) == 0 {
+		log.Errorf("invalid public key: %v", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	for _, key := range keys {
+		if k, _ := store.GetKeyByKeyID(c, r.Repo, key.KeyID); k != nil {
+			c.AbortWithStatus(http.StatusConflict)
+			return
+		}
+
+		key.RepoID = r.ID
+		err = store.CreateKey(c, key)
+		if err != nil {
+			log.Errorf("failed to add key '%s' to repo '%s/%s': %s", key.KeyID, r.Owner, r.Name, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// DeleteRepoKey removes a key from the trusted packager keys of a repo.
+func DeleteRepoKey(c *gin.Context) {
+	r := session.Repo(c)
+	keyID := c.Param("keyid")
+
+	key, err := store.GetKeyByKeyID(c, r.Repo, keyID)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	err = store.DeleteKey(c, key)
+	if err != nil {
+		log.Errorf("failed to delete key '%s' from repo '%s/%s': %s", keyID, r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/common/util"
+	"github.com/mikkeloscar/maze/remote"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	"github.com/mikkeloscar/maze/source"
+	"github.com/mikkeloscar/maze/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// obsoletePkgs returns the obsolete packages of each arch of a repo based on
+// the packages.yml of the source repo. The packages of all configured
+// sources are wanted. Entries of unknown sources are taken as package names.
+func obsoletePkgs(c *gin.Context, r *repo.Repo) (map[string][]string, error) {
+	owner, err := store.GetUser(c, r.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	rem := remote.FromContext(c)
+
+	conf, err := rem.GetConfig(owner, r.SourceOwner, r.SourceName, "packages.yml")
+	if err != nil {
+		return nil, err
+	}
+
+	files := remote.NewFileReader(rem, owner, r.SourceOwner, r.SourceName)
+
+	var wanted []string
+
+	for name := range conf.Sources {
+		entries := conf.Section(name)
+
+		if src, ok := source.Get(name); ok {
+			entries, err = source.Packages(src, entries, files)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		wanted = append(wanted, entries...)
+	}
+
+	obsolete := make(map[string][]string, len(r.Archs))
+
+	for _, arch := range r.Archs {
+		pkgs, err := r.Obsolete(wanted, arch)
+		if err != nil {
+			return nil, err
+		}
+		obsolete[arch] = pkgs
+	}
+
+	return obsolete, nil
+}
+
+// GetObsolete lists the packages of a repo which are no longer wanted
+// according to the packages.yml of the source repo. Nothing is removed.
+func GetObsolete(c *gin.Context) {
+	r := session.Repo(c)
+
+	obsolete, err := obsoletePkgs(c, r)
+	if err != nil {
+		log.Errorf("failed to get obsolete packages of repo '%s/%s': %s", r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, obsolete)
+}
+
+// PostObsolete removes obsolete packages from a repo. Only the packages
+// confirmed in the request which are still obsolete are removed.
+func PostObsolete(c *gin.Context) {
+	r := session.Repo(c)
+
+	in := struct {
+		Packages map[string][]string `json:"packages" binding:"required"`
+	}{}
+	err := c.BindJSON(&in)
+	if err != nil {
+		log.Errorf("failed to parse request body: %s", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	obsolete, err := obsoletePkgs(c, r)
+	if err != nil {
+		log.Errorf("failed to get obsolete packages of repo '%s/%s': %s", r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	removed := make(map[string][]string, len(obsolete))
+
+	for arch, pkgs := range obsolete {
+		remove := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			if util.StrContains(pkg, in.Packages[arch]) {
+				remove = append(remove, pkg)
+			}
+		}
+
+		if len(remove) > 0 {
+			err = r.Remove(remove, arch)
+			if err != nil {
+				log.Errorf("failed to remove obsolete packages from repo '%s/%s': %s", r.Owner, r.Name, err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		removed[arch] = remove
+	}
+
+	c.JSON(http.StatusOK, removed)
+}
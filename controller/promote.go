@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	"github.com/mikkeloscar/maze/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostPromote promotes package versions of a repo to a target repo. The user
+// must have write access to the target repo and, when moving the packages,
+// to the source repo.
+func PostPromote(c *gin.Context) {
+	user := session.User(c)
+	r := session.Repo(c)
+
+	in := struct {
+		Target   string `json:"target" binding:"required"`
+		Packages []struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"packages" binding:"required"`
+		Move bool `json:"move"`
+	}{}
+	err := c.BindJSON(&in)
+	if err != nil {
+		log.Errorf("failed to parse request body: %s", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if len(in.Packages) == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	pkgs := make(map[string]string, len(in.Packages))
+	for _, pkg := range in.Packages {
+		if pkg.Name == "" || pkg.Version == "" {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		pkgs[pkg.Name] = pkg.Version
+	}
+
+	owner, name, err := splitRepoName(in.Target)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	target, err := store.GetRepoByOwnerName(c, owner, name)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if target.ID == r.ID {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	perm := session.Perm(user, target)
+	if !perm.Read {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if !perm.Write || (in.Move && !session.RepoPerm(c).Write) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	targetRepo := repo.NewRepo(target, repo.RepoStorage)
+
+	err = ensureSigningKey(c, targetRepo)
+	if err != nil {
+		log.Errorf("failed to create signing key for repo '%s/%s': %s", target.Owner, target.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	keys, err := store.GetRepoKeys(c, target)
+	if err != nil {
+		log.Errorf("failed to get trusted keys for repository '%s': %s", target.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	err = r.Promote(targetRepo, pkgs, in.Move, keys, linkedRepos(c, target))
+	if err != nil {
+		if errors.Is(err, repo.ErrVersionNotFound) {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+
+		if serr, ok := err.(*repo.SignatureError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  serr.Err.Error(),
+				"file":   serr.File,
+				"key_id": serr.KeyID,
+			})
+			return
+		}
+
+		if derr, ok := err.(*repo.DepsError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   "unsatisfied dependencies",
+				"missing": derr.Missing,
+			})
+			return
+		}
+		log.Errorf("failed to promote packages from '%s/%s' to '%s/%s': %s", r.Owner, r.Name, target.Owner, target.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	promotions := make([]*model.Promotion, 0, len(in.Packages))
+	now := time.Now().UTC()
+
+	for name, version := range pkgs {
+		promotion := &model.Promotion{
+			SourceRepoID: r.ID,
+			TargetRepoID: target.ID,
+			Source:       r.Owner + "/" + r.Name,
+			Target:       target.Owner + "/" + target.Name,
+			Package:      name,
+			Version:      version,
+			Move:         in.Move,
+			UserID:       user.ID,
+			User:         user.Login,
+			Created:      now,
+		}
+
+		err = store.CreatePromotion(c, promotion)
+		if err != nil {
+			log.Errorf("failed to record promotion of '%s': %s", name, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		promotions = append(promotions, promotion)
+	}
+
+	c.JSON(http.StatusOK, promotions)
+}
+
+// GetPromotions lists the promotions from or to a repo.
+func GetPromotions(c *gin.Context) {
+	r := session.Repo(c)
+
+	promotions, err := store.GetRepoPromotions(c, r.Repo)
+	if err != nil {
+		log.Errorf("failed to get promotions of repo '%s/%s': %s", r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if promotions == nil {
+		promotions = []*model.Promotion{}
+	}
+
+	c.JSON(http.StatusOK, promotions)
+}
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	log "github.com/sirupsen/logrus"
+)
+
+// PostRebuild regenerates the dbs of a repo from the package files.
+func PostRebuild(c *gin.Context) {
+	r := session.Repo(c)
+
+	results, err := r.Rebuild()
+	if err != nil {
+		log.Errorf("failed to rebuild repo '%s/%s': %s", r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -8,7 +8,6 @@ import (
 	"strings"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/securecookie"
 	"github.com/mikkeloscar/maze/common/util"
@@ -16,15 +15,101 @@ import (
 	"github.com/mikkeloscar/maze/repo"
 	"github.com/mikkeloscar/maze/router/middleware/session"
 	"github.com/mikkeloscar/maze/store"
+	log "github.com/sirupsen/logrus"
 )
 
 func ServeRepoFile(c *gin.Context) {
 	repo := session.Repo(c)
 	arch := c.Param("arch")
 	file := c.Param("file")
-	c.File(path.Join(repo.PathDeep(arch), file))
+
+	if !util.StrContains(arch, repo.Archs) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	serveFile(c, repo, path.Join(repo.PathDeep(arch), file))
 }
 
+// presignExpiry is how long presigned URLs for repo files are valid.
+const presignExpiry = 15 * time.Minute
+
+// serveFile serves a file from the storage of a repo. If the storage
+// supports direct downloads the client is redirected to a presigned URL.
+func serveFile(c *gin.Context, r *repo.Repo, name string) {
+	st := r.Storage()
+
+	if _, ok := st.(*repo.LocalStorage); ok {
+		c.File(name)
+		return
+	}
+
+	url, err := st.URL(name, presignExpiry)
+	if err != nil {
+		log.Errorf("failed to get url of file '%s': %s", name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if url != "" {
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	info, err := st.Stat(name)
+	if err != nil || info.IsDir {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	f, err := st.Open(name)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	c.DataFromReader(http.StatusOK, info.Size, "application/octet-stream", f, nil)
+}
+
+// ServeRepoKey serves the public part of the repo signing key.
+func ServeRepoKey(c *gin.Context) {
+	repo := session.Repo(c)
+
+	key, err := repo.PublicKey()
+	if err != nil {
+		log.Errorf("failed to read signing key of repo '%s/%s': %s", repo.Owner, repo.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if key == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/pgp-keys", key)
+}
+
+// ensureSigningKey generates a signing key for repos created before
+// signing was supported.
+func ensureSigningKey(c *gin.Context, r *repo.Repo) error {
+	if r.SigningKey != "" {
+		return nil
+	}
+
+	key, err := repo.NewSigningKey(r.Owner, r.Name)
+	if err != nil {
+		return err
+	}
+
+	r.SigningKey = key
+	return store.UpdateRepo(c, r.Repo)
+}
+
+// defaultHistorySize is the number of old package versions kept by new
+// repos.
+const defaultHistorySize = 3
+
 func splitRepoName(source string) (string, string, error) {
 	split := strings.Split(source, "/")
 	if len(split) != 2 {
@@ -51,11 +136,17 @@ func PostRepo(c *gin.Context) {
 	}
 
 	in := struct {
-		SourceRepo   *string   `json:"source_repo" binding:"required"`
-		SourceBranch *string   `json:"source_branch,omitempty"`
-		BuildBranch  *string   `json:"build_branch,omitempty"`
-		Archs        *[]string `json:"archs,omitempty"`
-		Private      *bool     `json:"private,omitempty"`
+		SourceRepo        *string   `json:"source_repo" binding:"required"`
+		SourceBranch      *string   `json:"source_branch,omitempty"`
+		BuildBranch       *string   `json:"build_branch,omitempty"`
+		Archs             *[]string `json:"archs,omitempty"`
+		Private           *bool     `json:"private,omitempty"`
+		HistorySize       *int      `json:"history_size,omitempty"`
+		SnapshotDaily     *bool     `json:"snapshot_daily,omitempty"`
+		SnapshotRetention *int      `json:"snapshot_retention,omitempty"`
+		CheckDeps         *bool     `json:"check_deps,omitempty"`
+		RequireSignatures *bool     `json:"require_signatures,omitempty"`
+		LinkedRepos       *[]string `json:"linked_repos,omitempty"`
 	}{}
 	err := c.BindJSON(&in)
 	if err != nil {
@@ -79,6 +170,26 @@ func PostRepo(c *gin.Context) {
 		return
 	}
 
+	if in.HistorySize != nil && *in.HistorySize < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if in.SnapshotRetention != nil && *in.SnapshotRetention < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if in.LinkedRepos == nil {
+		linked := []string{}
+		in.LinkedRepos = &linked
+	}
+
+	if !validLinkedRepos(c, user, owner, name, *in.LinkedRepos) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
 	sourceOwner, sourceName, err := splitRepoName(*in.SourceRepo)
 	if err != nil {
 		log.Error(err)
@@ -138,11 +249,36 @@ func PostRepo(c *gin.Context) {
 	r.Private = *in.Private
 	r.SourceBranch = *in.SourceBranch
 	r.BuildBranch = *in.BuildBranch
+	r.Archs = *in.Archs
+	r.HistorySize = defaultHistorySize
+	if in.HistorySize != nil {
+		r.HistorySize = *in.HistorySize
+	}
+	if in.SnapshotDaily != nil {
+		r.SnapshotDaily = *in.SnapshotDaily
+	}
+	if in.SnapshotRetention != nil {
+		r.SnapshotRetention = *in.SnapshotRetention
+	}
+	if in.CheckDeps != nil {
+		r.CheckDeps = *in.CheckDeps
+	}
+	if in.RequireSignatures != nil {
+		r.RequireSignatures = *in.RequireSignatures
+	}
+	r.LinkedRepos = *in.LinkedRepos
 	r.LastCheck = time.Now().UTC().Add(-1 * time.Hour)
 	r.Hash = base32.StdEncoding.EncodeToString(
 		securecookie.GenerateRandomKey(32),
 	)
 
+	r.SigningKey, err = repo.NewSigningKey(owner, name)
+	if err != nil {
+		log.Errorf("failed to generate signing key: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
 	fsRepo := repo.NewRepo(r, repo.RepoStorage)
 
 	err = fsRepo.InitDir()
@@ -178,14 +314,22 @@ func GetRepo(c *gin.Context) {
 }
 
 func PatchRepo(c *gin.Context) {
+	user := session.User(c)
 	r := session.Repo(c)
 
 	in := struct {
-		SourceOwner  *string `json:"source_owner,omitempty"`
-		SourceName   *string `json:"source_name,omitempty"`
-		SourceBranch *string `json:"source_branch,omitempty"`
-		BuildBranch  *string `json:"build_branch,omitempty"`
-		Name         *string `json:"name,omitempty"`
+		SourceOwner       *string   `json:"source_owner,omitempty"`
+		SourceName        *string   `json:"source_name,omitempty"`
+		SourceBranch      *string   `json:"source_branch,omitempty"`
+		BuildBranch       *string   `json:"build_branch,omitempty"`
+		Name              *string   `json:"name,omitempty"`
+		Archs             *[]string `json:"archs,omitempty"`
+		HistorySize       *int      `json:"history_size,omitempty"`
+		SnapshotDaily     *bool     `json:"snapshot_daily,omitempty"`
+		SnapshotRetention *int      `json:"snapshot_retention,omitempty"`
+		CheckDeps         *bool     `json:"check_deps,omitempty"`
+		RequireSignatures *bool     `json:"require_signatures,omitempty"`
+		LinkedRepos       *[]string `json:"linked_repos,omitempty"`
 	}{}
 
 	err := c.BindJSON(&in)
@@ -195,40 +339,145 @@ func PatchRepo(c *gin.Context) {
 		return
 	}
 
+	// validate the whole input before touching the storage of the repo.
+	if in.Name != nil && !repo.ValidRepoName(*in.Name) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if in.HistorySize != nil && *in.HistorySize < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if in.SnapshotRetention != nil && *in.SnapshotRetention < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if in.LinkedRepos != nil && !validLinkedRepos(c, user, r.Owner, r.Name, *in.LinkedRepos) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if in.Archs != nil && (len(*in.Archs) == 0 || !repo.ValidArchs(*in.Archs)) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	updated := *r.Repo
+
 	if in.SourceOwner != nil {
-		r.SourceOwner = *in.SourceOwner
+		updated.SourceOwner = *in.SourceOwner
 	}
 
 	if in.SourceName != nil {
-		r.SourceName = *in.SourceName
+		updated.SourceName = *in.SourceName
 	}
 
 	if in.SourceBranch != nil {
-		r.SourceBranch = *in.SourceBranch
+		updated.SourceBranch = *in.SourceBranch
 	}
 
 	if in.BuildBranch != nil {
-		r.BuildBranch = *in.BuildBranch
+		updated.BuildBranch = *in.BuildBranch
 	}
 
 	if in.Name != nil {
-		r.Name = *in.Name
-		if !repo.ValidRepoName(r.Name) {
-			c.AbortWithStatus(http.StatusBadRequest)
+		updated.Name = *in.Name
+	}
+
+	if in.HistorySize != nil {
+		updated.HistorySize = *in.HistorySize
+	}
+
+	if in.SnapshotDaily != nil {
+		updated.SnapshotDaily = *in.SnapshotDaily
+	}
+
+	if in.SnapshotRetention != nil {
+		updated.SnapshotRetention = *in.SnapshotRetention
+	}
+
+	if in.CheckDeps != nil {
+		updated.CheckDeps = *in.CheckDeps
+	}
+
+	if in.RequireSignatures != nil {
+		updated.RequireSignatures = *in.RequireSignatures
+	}
+
+	if in.LinkedRepos != nil {
+		updated.LinkedRepos = *in.LinkedRepos
+	}
+
+	var added, removed []string
+	if in.Archs != nil {
+		for _, arch := range *in.Archs {
+			if !util.StrContains(arch, r.Archs) && !util.StrContains(arch, added) {
+				added = append(added, arch)
+			}
+		}
+
+		for _, arch := range r.Archs {
+			if !util.StrContains(arch, *in.Archs) {
+				removed = append(removed, arch)
+			}
+		}
+
+		archs := make([]string, 0, len(r.Archs)+len(added))
+		for _, arch := range append(append([]string(nil), r.Archs...), added...) {
+			if !util.StrContains(arch, removed) {
+				archs = append(archs, arch)
+			}
+		}
+		updated.Archs = archs
+	}
+
+	// new archs are added before the repo is updated and removed again
+	// if the update fails.
+	for i, arch := range added {
+		err = r.AddArch(arch)
+		if err != nil {
+			log.Errorf("failed to add arch '%s' to repo '%s/%s': %s", arch, r.Owner, r.Name, err)
+			removeArchs(r, added[:i])
+			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
 	}
 
-	err = store.UpdateRepo(c, r.Repo)
+	err = store.UpdateRepo(c, &updated)
 	if err != nil {
 		log.Errorf("failed to update repo '%s/%s': %s", r.Owner, r.Name, err)
+		removeArchs(r, added)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
+	// packages of removed archs can't be restored, so they are only
+	// deleted once the repo is updated.
+	removeArchs(r, removed)
+
+	err = r.SetName(updated.Name)
+	if err != nil {
+		log.Errorf("failed to rename repo '%s/%s': %s", r.Owner, r.Name, err)
+	}
+
+	*r.Repo = updated
+
 	c.JSON(http.StatusOK, r)
 }
 
+// removeArchs removes archs from the storage of a repo, logging failures.
+func removeArchs(r *repo.Repo, archs []string) {
+	for _, arch := range archs {
+		err := r.RemoveArch(arch)
+		if err != nil {
+			log.Errorf("failed to remove arch '%s' from repo '%s/%s': %s", arch, r.Owner, r.Name, err)
+		}
+	}
+}
+
 func DeleteRepo(c *gin.Context) {
 	repo := session.Repo(c)
 
@@ -318,6 +567,50 @@ func GetRepoPackageFiles(c *gin.Context) {
 	c.JSON(http.StatusOK, pkg.Files)
 }
 
+func GetRepoGroups(c *gin.Context) {
+	repo := session.Repo(c)
+	arch := c.Param("arch")
+
+	if !util.StrContains(arch, repo.Archs) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	groups, err := repo.Groups(arch)
+	if err != nil {
+		log.Errorf("Failed to get repo groups: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+func GetRepoGroup(c *gin.Context) {
+	repo := session.Repo(c)
+	group := c.Param("group")
+	arch := c.Param("arch")
+
+	if !util.StrContains(arch, repo.Archs) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	pkgs, err := repo.Group(group, arch)
+	if err != nil {
+		log.Errorf("Failed to get repo group '%s': %s", group, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if len(pkgs) == 0 {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, pkgs)
+}
+
 func DeleteRepoPackage(c *gin.Context) {
 	repo := session.Repo(c)
 	pkgname := c.Param("package")
@@ -349,3 +642,70 @@ func DeleteRepoPackage(c *gin.Context) {
 
 	c.Status(http.StatusOK)
 }
+
+func GetRepoPackageHistory(c *gin.Context) {
+	repo := session.Repo(c)
+	pkgname := c.Param("package")
+	arch := c.Param("arch")
+
+	if !util.StrContains(arch, repo.Archs) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	versions, err := repo.History(pkgname, arch)
+	if err != nil {
+		log.Errorf("Failed to get history of repo package '%s': %s", pkgname, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if len(versions) == 0 {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+func PostRepoPackageRollback(c *gin.Context) {
+	r := session.Repo(c)
+	pkgname := c.Param("package")
+	arch := c.Param("arch")
+
+	if !util.StrContains(arch, r.Archs) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	in := struct {
+		Version string `json:"version" binding:"required"`
+	}{}
+	err := c.BindJSON(&in)
+	if err != nil {
+		log.Errorf("failed to parse request body: %s", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	err = r.Rollback(pkgname, arch, in.Version)
+	if err != nil {
+		if err == repo.ErrVersionNotFound {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		log.Errorf("Failed to rollback repo package '%s' to version '%s': %s", pkgname, in.Version, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	pkg, err := r.Package(pkgname, arch, false)
+	if err != nil {
+		log.Errorf("Failed to get repo package '%s': %s", pkgname, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, pkg)
+}
@@ -1,59 +1,115 @@
 package controller
 
 import (
+	"database/sql"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
 	"github.com/mikkeloscar/maze/router/middleware/session"
+	"github.com/mikkeloscar/maze/store"
+	"github.com/mikkeloscar/maze/upload"
 	uuid "github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
 )
 
-var sessions = map[string][]string{}
+// MetaPkg is a multipart form of package files and their signatures.
+type MetaPkg struct {
+	Packages   []*multipart.FileHeader `form:"package" binding:"required"`
+	Signatures []*multipart.FileHeader `form:"signature"`
+}
+
+// Statuses of files uploaded in a single request.
+const (
+	uploadAdded    = "added"
+	uploadSkipped  = "skipped"
+	uploadRejected = "rejected"
+)
 
-func getSessionID() string {
-	for {
-		u := uuid.NewV4().String()
-		if _, ok := sessions[u]; !ok {
-			sessions[u] = make([]string, 0)
-			return u
+// uploadSession returns the upload session of the request. The request is
+// aborted if the session doesn't exist, has expired or belongs to another
+// repo or user.
+func uploadSession(c *gin.Context, r *repo.Repo) (*model.UploadSession, bool) {
+	sessionID := c.Param("sessionid")
+
+	sess, err := store.GetUploadSession(c, sessionID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Errorf("failed to get upload session %s: %s", sessionID, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return nil, false
 		}
+
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
 	}
+
+	user := session.User(c)
+	if user == nil || sess.UserID != user.ID || sess.RepoID != r.ID || time.Now().After(sess.Expires) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+
+	return sess, true
 }
 
-type MetaPkg struct {
-	Package   string `json:"package" binding:"required"`
-	Signature string `json:"signature" binding:"required"`
+// removeUploadSession removes an upload session and its files.
+func removeUploadSession(c *gin.Context, r *repo.Repo, sess *model.UploadSession) error {
+	err := store.DeleteUploadSession(c, sess)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(r.UploadSessionPath(sess.SessionID))
 }
 
 func PostUploadStart(c *gin.Context) {
-	// name := c.Param("name")
-	// repository := repo.GetByName(name)
-	// repo := session.Repo(c)
+	r := session.Repo(c)
+	user := session.User(c)
 
-	c.JSON(http.StatusOK, gin.H{
-		"session_id": getSessionID(),
-	})
+	now := time.Now().UTC()
+	sess := &model.UploadSession{
+		SessionID: uuid.NewV4().String(),
+		RepoID:    r.ID,
+		UserID:    user.ID,
+		Created:   now,
+		Expires:   now.Add(upload.SessionTTL),
+	}
+
+	err := store.CreateUploadSession(c, sess)
+	if err != nil {
+		log.Errorf("failed to create upload session: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, sess)
 }
 
 func PostUploadFile(c *gin.Context) {
 	pkg := c.Param("filename")
-	sessionID := c.Param("sessionid")
-	repo := session.Repo(c)
+	r := session.Repo(c)
 
-	if _, ok := sessions[sessionID]; !ok {
-		c.AbortWithStatus(http.StatusNotFound)
+	sess, ok := uploadSession(c, r)
+	if !ok {
+		return
 	}
 
-	// TODO check valid filename
-	// TODO check valid file content
-	// TODO: clear session on error
+	if strings.ContainsAny(pkg, `/\`) || !repo.ValidPkgFilename(pkg) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid package filename",
+		})
+		return
+	}
 
-	new, err := repo.IsNewFilename(pkg)
+	new, err := r.IsNewFilename(pkg)
 	if err != nil {
 		c.AbortWithError(400, err)
 		return
@@ -64,43 +120,453 @@ func PostUploadFile(c *gin.Context) {
 		return
 	}
 
-	pkg = path.Join(repo.Path(), pkg)
+	dir := r.UploadSessionPath(sess.SessionID)
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		log.Errorf("failed to create upload path %s: %s", dir, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	pkgPath := path.Join(dir, pkg)
 
-	f, err := os.Create(pkg)
+	f, err := os.Create(pkgPath)
 	if err != nil {
-		log.Errorf("failed to create file %s: %s", pkg, err)
+		log.Errorf("failed to create file %s: %s", pkgPath, err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
-	_, err = io.Copy(f, c.Request.Body)
+	size, err := io.Copy(f, c.Request.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
+		removeFiles([]string{pkgPath})
 		log.Errorf("failed to write data: %s", err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
-	sessions[sessionID] = append(sessions[sessionID], pkg)
+	var meta *model.Package
+
+	if !strings.HasSuffix(pkg, ".sig") {
+		meta, err = repo.ValidatePkgFile(pkgPath)
+		if err != nil {
+			removeFiles([]string{pkgPath})
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+
+	err = store.AddUploadFile(c, &model.UploadFile{
+		SessionID: sess.ID,
+		Path:      pkgPath,
+		Package:   meta,
+		Size:      size,
+		Complete:  true,
+		Created:   now,
+	})
+	if err != nil {
+		removeFiles([]string{pkgPath})
+		log.Errorf("failed to add file to upload session %s: %s", sess.SessionID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	sess.Expires = now.Add(upload.SessionTTL)
+	err = store.UpdateUploadSession(c, sess)
+	if err != nil {
+		log.Errorf("failed to update upload session %s: %s", sess.SessionID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
 
 	c.Writer.WriteHeader(http.StatusOK)
 }
 
 func PostUploadDone(c *gin.Context) {
-	sessionID := c.Param("sessionid")
-	repo := session.Repo(c)
+	r := session.Repo(c)
+
+	sess, ok := uploadSession(c, r)
+	if !ok {
+		return
+	}
+
+	files, err := store.GetUploadFiles(c, sess)
+	if err != nil {
+		log.Errorf("failed to get files of upload session %s: %s", sess.SessionID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// resumable uploads must be completed before the session can be
+	// finished.
+	var incomplete []string
+	for _, file := range files {
+		if !file.Complete {
+			incomplete = append(incomplete, path.Base(file.Path))
+		}
+	}
+
+	if len(incomplete) > 0 {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "incomplete uploads",
+			"files": incomplete,
+		})
+		return
+	}
+
+	// the session is done whatever the outcome.
+	defer func() {
+		err := removeUploadSession(c, r, sess)
+		if err != nil {
+			log.Errorf("failed to remove upload session %s: %s", sess.SessionID, err)
+		}
+	}()
+
+	pkgs := make([]string, 0, len(files))
+	metas := make([]*model.Package, 0, len(files))
+	for _, file := range files {
+		pkgs = append(pkgs, file.Path)
+		if file.Package != nil {
+			metas = append(metas, file.Package)
+		}
+	}
+
+	err = ensureSigningKey(c, r)
+	if err != nil {
+		log.Errorf("failed to setup signing key for repository '%s': %s", r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	keys, err := store.GetRepoKeys(c, r.Repo)
+	if err != nil {
+		log.Errorf("failed to get trusted keys for repository '%s': %s", r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	err = r.CheckPkgSignatures(pkgs, keys)
+	if err != nil {
+		if serr, ok := err.(*repo.SignatureError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  serr.Err.Error(),
+				"file":   serr.File,
+				"key_id": serr.KeyID,
+			})
+			return
+		}
+
+		log.Errorf("failed to verify package signatures: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
 
-	if pkgs, ok := sessions[sessionID]; ok {
-		delete(sessions, sessionID)
-		err := repo.Add(pkgs)
+	if r.CheckDeps {
+		err = r.VerifyDeps(pkgs, linkedRepos(c, r.Repo))
 		if err != nil {
-			log.Errorf("failed to add packages '%s' to repository '%s': %s", strings.Join(pkgs, ", "), repo.Name, err)
+			if derr, ok := err.(*repo.DepsError); ok {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error":   "unsatisfied dependencies",
+					"missing": derr.Missing,
+				})
+				return
+			}
+
+			log.Errorf("failed to check package dependencies: %s", err)
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
+	}
+
+	err = r.Add(pkgs)
+	if err != nil {
+		log.Errorf("failed to add packages '%s' to repository '%s': %s", strings.Join(pkgs, ", "), r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"packages": metas,
+	})
+}
+
+// DeleteUpload aborts an upload session removing the uploaded files.
+func DeleteUpload(c *gin.Context) {
+	r := session.Repo(c)
+
+	sess, ok := uploadSession(c, r)
+	if !ok {
+		return
+	}
+
+	err := removeUploadSession(c, r, sess)
+	if err != nil {
+		log.Errorf("failed to remove upload session %s: %s", sess.SessionID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
 
-		c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// PostRepoPackages adds package files and their signatures uploaded in a
+// single multipart request. Packages which aren't newer than the ones in the
+// repo are skipped and invalid packages are rejected; the rest are added to
+// the repo together. The result of every uploaded file is returned.
+func PostRepoPackages(c *gin.Context) {
+	r := session.Repo(c)
+
+	var form MetaPkg
+	err := c.ShouldBind(&form)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
 		return
 	}
 
-	c.AbortWithStatus(http.StatusNotFound)
+	keys, err := store.GetRepoKeys(c, r.Repo)
+	if err != nil {
+		log.Errorf("failed to get trusted keys for repository '%s': %s", r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	dir := r.UploadSessionPath(uuid.NewV4().String())
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		log.Errorf("failed to create upload path %s: %s", dir, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	defer func() {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			log.Errorf("failed to remove upload path %s: %s", dir, err)
+		}
+	}()
+
+	results := make([]*model.UploadResult, 0, len(form.Packages)+len(form.Signatures))
+
+	reject := func(result *model.UploadResult, reason string) {
+		result.Status = uploadRejected
+		result.Reason = reason
+	}
+
+	sigs := make(map[string]*multipart.FileHeader, len(form.Signatures))
+	sigResults := make(map[string]*model.UploadResult, len(form.Signatures))
+
+	for _, sig := range form.Signatures {
+		result := &model.UploadResult{File: sig.Filename}
+
+		switch pkg := strings.TrimSuffix(sig.Filename, ".sig"); {
+		case pkg == sig.Filename:
+			reject(result, "not a signature file")
+		case sigs[pkg] != nil:
+			reject(result, "duplicate file")
+		default:
+			sigs[pkg] = sig
+			sigResults[pkg] = result
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	type pendingPkg struct {
+		result *model.UploadResult
+		sig    *model.UploadResult
+		path   string
+	}
+
+	var pending []*pendingPkg
+	names := make(map[string]struct{}, len(form.Packages))
+	files := make(map[string]struct{}, len(form.Packages))
+
+	for _, fh := range form.Packages {
+		filename := fh.Filename
+		result := &model.UploadResult{File: filename}
+		results = append(results, result)
+
+		sig := sigs[filename]
+		sigResult := sigResults[filename]
+		delete(sigs, filename)
+
+		rejectPkg := func(reason string) {
+			reject(result, reason)
+			if sigResult != nil {
+				reject(sigResult, "package "+reason)
+				results = append(results, sigResult)
+			}
+		}
+
+		if strings.ContainsAny(filename, `/\`) || strings.HasSuffix(filename, ".sig") || !repo.ValidPkgFilename(filename) {
+			rejectPkg("invalid package filename")
+			continue
+		}
+
+		if _, ok := files[filename]; ok {
+			rejectPkg("duplicate file")
+			continue
+		}
+		files[filename] = struct{}{}
+
+		new, err := r.IsNewFilename(filename)
+		if err != nil {
+			rejectPkg(err.Error())
+			continue
+		}
+
+		if !new {
+			result.Status = uploadSkipped
+			result.Reason = "not newer than the package in the repository"
+			if sigResult != nil {
+				sigResult.Status = uploadSkipped
+				sigResult.Reason = "package " + result.Reason
+				results = append(results, sigResult)
+			}
+			continue
+		}
+
+		pkgPath := path.Join(dir, filename)
+
+		err = c.SaveUploadedFile(fh, pkgPath)
+		if err == nil && sig != nil {
+			err = c.SaveUploadedFile(sig, pkgPath+".sig")
+		}
+		if err != nil {
+			log.Errorf("failed to write data: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		meta, err := repo.ValidatePkgFile(pkgPath)
+		if err != nil {
+			rejectPkg(err.Error())
+			continue
+		}
+
+		if _, ok := names[meta.Name]; ok {
+			rejectPkg("duplicate package " + meta.Name)
+			continue
+		}
+
+		err = r.CheckPkgSignatures([]string{pkgPath}, keys)
+		if err != nil {
+			if serr, ok := err.(*repo.SignatureError); ok {
+				rejectPkg(serr.Error())
+				continue
+			}
+
+			log.Errorf("failed to verify package signatures: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		names[meta.Name] = struct{}{}
+		result.Package = meta
+		pending = append(pending, &pendingPkg{result: result, sig: sigResult, path: pkgPath})
+		if sigResult != nil {
+			results = append(results, sigResult)
+		}
+	}
+
+	for _, sig := range form.Signatures {
+		pkg := strings.TrimSuffix(sig.Filename, ".sig")
+		if sigs[pkg] == sig {
+			reject(sigResults[pkg], "no matching package")
+			results = append(results, sigResults[pkg])
+		}
+	}
+
+	pkgPaths := func() []string {
+		paths := make([]string, 0, len(pending))
+		for _, p := range pending {
+			paths = append(paths, p.path)
+		}
+		return paths
+	}
+
+	// packages with unsatisfied dependencies are rejected until the
+	// remaining packages can be added.
+	for r.CheckDeps && len(pending) > 0 {
+		err = r.VerifyDeps(pkgPaths(), linkedRepos(c, r.Repo))
+		if err == nil {
+			break
+		}
+
+		derr, ok := err.(*repo.DepsError)
+		if !ok {
+			log.Errorf("failed to check package dependencies: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		missing := make(map[string][]string)
+		for _, m := range derr.Missing {
+			missing[m.Package] = append(missing[m.Package], m.Depend)
+		}
+
+		remaining := pending[:0]
+		for _, p := range pending {
+			if deps, ok := missing[p.result.Package.Name]; ok {
+				reason := "unsatisfied dependencies: " + strings.Join(deps, ", ")
+				reject(p.result, reason)
+				p.result.Package = nil
+				if p.sig != nil {
+					reject(p.sig, "package "+reason)
+				}
+				continue
+			}
+			remaining = append(remaining, p)
+		}
+		pending = remaining
+	}
+
+	if len(pending) > 0 {
+		err = ensureSigningKey(c, r)
+		if err != nil {
+			log.Errorf("failed to setup signing key for repository '%s': %s", r.Name, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		err = r.Add(pkgPaths())
+		if err != nil {
+			log.Errorf("failed to add packages '%s' to repository '%s': %s", strings.Join(pkgPaths(), ", "), r.Name, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for _, p := range pending {
+		p.result.Status = uploadAdded
+		if p.sig != nil {
+			p.sig.Status = uploadAdded
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+	})
+}
+
+// removeFiles removes uploaded files which won't be added to the repo.
+func removeFiles(files []string) {
+	for _, file := range files {
+		err := os.Remove(file)
+		if err != nil && !os.IsNotExist(err) {
+			log.Errorf("failed to remove file %s: %s", file, err)
+		}
+	}
 }
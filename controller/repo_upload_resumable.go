@@ -0,0 +1,387 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	"github.com/mikkeloscar/maze/store"
+	"github.com/mikkeloscar/maze/upload"
+	log "github.com/sirupsen/logrus"
+)
+
+// Resumable uploads implement the core, creation and checksum extensions of
+// the tus protocol (https://tus.io/protocols/resumable-upload).
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,checksum"
+	tusChecksums  = "sha256"
+	tusContent    = "application/offset+octet-stream"
+
+	// statusChecksumMismatch is the tus status for a chunk checksum
+	// mismatch.
+	statusChecksumMismatch = 460
+)
+
+var sha256Patt = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// parseTusMetadata parses an Upload-Metadata header of comma separated key
+// and base64 encoded value pairs.
+func parseTusMetadata(header string) (map[string]string, error) {
+	meta := make(map[string]string)
+
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(pair, " ")
+		v, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, errors.New("invalid Upload-Metadata value for key " + key)
+		}
+		meta[key] = string(v)
+	}
+
+	return meta, nil
+}
+
+// parseTusChecksum parses an Upload-Checksum header. nil is returned if the
+// header isn't set.
+func parseTusChecksum(header string) ([]byte, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	algo, value, _ := strings.Cut(header, " ")
+	if algo != tusChecksums {
+		return nil, errors.New("unsupported checksum algorithm " + algo)
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("invalid Upload-Checksum value")
+	}
+
+	return sum, nil
+}
+
+func tusError(c *gin.Context, code int, msg string) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.AbortWithStatusJSON(code, gin.H{
+		"error": msg,
+	})
+}
+
+// resumableFile returns the resumable upload of the request. The request is
+// aborted if it doesn't exist.
+func resumableFile(c *gin.Context, r *repo.Repo, sess *model.UploadSession) (*model.UploadFile, bool) {
+	filename := c.Param("filename")
+	if strings.ContainsAny(filename, `/\`) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+
+	file, err := store.GetUploadFile(c, sess, path.Join(r.UploadSessionPath(sess.SessionID), filename))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Errorf("failed to get file %s of upload session %s: %s", filename, sess.SessionID, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return nil, false
+		}
+
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+
+	return file, true
+}
+
+// OptionsResumableUpload describes the supported tus protocol.
+func OptionsResumableUpload(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", tusChecksums)
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// PostResumableUpload creates a resumable upload in an upload session. The
+// total size is declared with the Upload-Length header and the filename and
+// hex encoded sha256 sum of the file with the Upload-Metadata header.
+func PostResumableUpload(c *gin.Context) {
+	r := session.Repo(c)
+
+	sess, ok := uploadSession(c, r)
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		tusError(c, http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+
+	meta, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		tusError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filename := meta["filename"]
+	if strings.ContainsAny(filename, `/\`) || !repo.ValidPkgFilename(filename) {
+		tusError(c, http.StatusBadRequest, "invalid package filename")
+		return
+	}
+
+	sha256sum := strings.ToLower(meta["sha256"])
+	if !sha256Patt.MatchString(sha256sum) {
+		tusError(c, http.StatusBadRequest, "invalid sha256 checksum")
+		return
+	}
+
+	new, err := r.IsNewFilename(filename)
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	if !new {
+		c.AbortWithStatus(208)
+		return
+	}
+
+	dir := r.UploadSessionPath(sess.SessionID)
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		log.Errorf("failed to create upload path %s: %s", dir, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	pkgPath := path.Join(dir, filename)
+
+	unlock := upload.Lock(pkgPath)
+	defer unlock()
+
+	// a previous complete upload of the file is replaced.
+	removeFiles([]string{pkgPath})
+
+	err = upload.Create(pkgPath)
+	if err != nil {
+		log.Errorf("failed to create file %s: %s", pkgPath, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	err = store.AddUploadFile(c, &model.UploadFile{
+		SessionID: sess.ID,
+		Path:      pkgPath,
+		Size:      length,
+		SHA256:    sha256sum,
+		Complete:  false,
+		Created:   now,
+	})
+	if err != nil {
+		removeFiles([]string{upload.PartPath(pkgPath)})
+		log.Errorf("failed to add file to upload session %s: %s", sess.SessionID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	sess.Expires = now.Add(upload.SessionTTL)
+	err = store.UpdateUploadSession(c, sess)
+	if err != nil {
+		log.Errorf("failed to update upload session %s: %s", sess.SessionID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+filename)
+	c.Header("Upload-Offset", "0")
+	c.Writer.WriteHeader(http.StatusCreated)
+}
+
+// HeadResumableUpload returns the number of bytes received of a resumable
+// upload in the Upload-Offset header.
+func HeadResumableUpload(c *gin.Context) {
+	r := session.Repo(c)
+
+	sess, ok := uploadSession(c, r)
+	if !ok {
+		return
+	}
+
+	file, ok := resumableFile(c, r, sess)
+	if !ok {
+		return
+	}
+
+	offset := file.Size
+	if !file.Complete {
+		var err error
+		offset, err = upload.Offset(file.Path)
+		if err != nil {
+			log.Errorf("failed to get offset of upload %s: %s", file.Path, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Length", strconv.FormatInt(file.Size, 10))
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	c.Writer.WriteHeader(http.StatusOK)
+}
+
+// PatchResumableUpload appends a chunk to a resumable upload. The chunk must
+// start at the offset given by the Upload-Offset header and can optionally be
+// verified with an Upload-Checksum header. Once all bytes are received the
+// sha256 sum of the file is verified and the package is validated; if either
+// fails the upload is removed and must be created again.
+func PatchResumableUpload(c *gin.Context) {
+	r := session.Repo(c)
+
+	sess, ok := uploadSession(c, r)
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != tusContent {
+		tusError(c, http.StatusUnsupportedMediaType, "Content-Type must be "+tusContent)
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		tusError(c, http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+
+	sum, err := parseTusChecksum(c.GetHeader("Upload-Checksum"))
+	if err != nil {
+		tusError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, ok := resumableFile(c, r, sess)
+	if !ok {
+		return
+	}
+
+	unlock := upload.Lock(file.Path)
+	defer unlock()
+
+	// the upload might have been completed or replaced while waiting for
+	// the lock.
+	file, ok = resumableFile(c, r, sess)
+	if !ok {
+		return
+	}
+
+	if file.Complete {
+		tusError(c, http.StatusConflict, "upload is already complete")
+		return
+	}
+
+	offset, err = upload.WriteChunk(file.Path, offset, file.Size, c.Request.Body, sum)
+	switch err {
+	case nil:
+	case upload.ErrOffsetMismatch:
+		c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+		tusError(c, http.StatusConflict, err.Error())
+		return
+	case upload.ErrChecksumMismatch:
+		tusError(c, statusChecksumMismatch, err.Error())
+		return
+	case upload.ErrTooLarge:
+		tusError(c, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	default:
+		log.Errorf("failed to write chunk of upload %s: %s", file.Path, err)
+		c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if offset == file.Size {
+		err = completeResumableUpload(file)
+		if err != nil {
+			rerr := store.DeleteUploadFile(c, file)
+			if rerr != nil {
+				log.Errorf("failed to remove file %s from upload session %s: %s", file.Path, sess.SessionID, rerr)
+			}
+			removeFiles([]string{file.Path, upload.PartPath(file.Path)})
+
+			if errors.Is(err, upload.ErrChecksumMismatch) || errors.Is(err, errInvalidPackage) {
+				tusError(c, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			log.Errorf("failed to complete upload %s: %s", file.Path, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		err = store.UpdateUploadFile(c, file)
+		if err != nil {
+			log.Errorf("failed to update file %s of upload session %s: %s", file.Path, sess.SessionID, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	sess.Expires = time.Now().UTC().Add(upload.SessionTTL)
+	err = store.UpdateUploadSession(c, sess)
+	if err != nil {
+		log.Errorf("failed to update upload session %s: %s", sess.SessionID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
+
+var errInvalidPackage = errors.New("invalid package")
+
+// completeResumableUpload verifies and moves a fully received file in place
+// and marks it as complete.
+func completeResumableUpload(file *model.UploadFile) error {
+	err := upload.Complete(file.Path, file.SHA256)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(file.Path, ".sig") {
+		meta, err := repo.ValidatePkgFile(file.Path)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidPackage, err)
+		}
+		file.Package = meta
+	}
+
+	file.Complete = true
+
+	return nil
+}
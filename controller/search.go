@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// intQuery returns the positive integer value of a query parameter or def
+// if it's not set. Values above limit are capped at limit.
+func intQuery(c *gin.Context, name string, def, limit int) (int, error) {
+	v := c.Query(name)
+	if v == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil || i <= 0 {
+		return 0, fmt.Errorf("invalid value for %s: %s", name, v)
+	}
+
+	if limit > 0 && i > limit {
+		i = limit
+	}
+
+	return i, nil
+}
+
+// GetSearch searches the packages of all readable repos. Packages match if
+// all terms of the query 'q' match the name, description, provides or
+// groups. Results are ranked by score and paginated by 'page' and
+// 'per_page'. The search can be limited to an 'arch', a repo 'owner' and a
+// 'packager'.
+func GetSearch(c *gin.Context) {
+	query := repo.NewSearchQuery(c.Query("q"))
+	if query.Empty() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "missing search query",
+		})
+		return
+	}
+	query.Packager = c.Query("packager")
+
+	page, err := intQuery(c, "page", 1, 0)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	perPage, err := intQuery(c, "per_page", defaultPerPage, maxPerPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	owner := c.Query("owner")
+	arch := c.Query("arch")
+
+	repos, err := readableRepos(c)
+	if err != nil {
+		log.Errorf("failed to get repos: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	results := []*model.SearchResult{}
+
+	for _, r := range repos {
+		if owner != "" && r.Owner != owner {
+			continue
+		}
+
+		for _, a := range r.Archs {
+			if arch != "" && a != arch {
+				continue
+			}
+
+			res, err := r.Search(a, query)
+			if err != nil {
+				log.Errorf("failed to search repo '%s/%s': %s", r.Owner, r.Name, err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+
+			results = append(results, res...)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Package.Name != b.Package.Name {
+			return a.Package.Name < b.Package.Name
+		}
+		if a.Owner != b.Owner {
+			return a.Owner < b.Owner
+		}
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		return a.Arch < b.Arch
+	})
+
+	total := len(results)
+	// pages beyond the results are empty, checked before multiplying such
+	// that huge pages can't overflow.
+	start := total
+	if page-1 <= total/perPage {
+		start = min((page-1)*perPage, total)
+	}
+	end := min(start+perPage, total)
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"results":  results[start:end],
+	})
+}
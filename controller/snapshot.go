@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	log "github.com/sirupsen/logrus"
+)
+
+// ServeSnapshotFile serves a file from a repo snapshot.
+func ServeSnapshotFile(c *gin.Context) {
+	r := session.Repo(c)
+	name := c.Param("snapshot")
+	arch := c.Param("arch")
+	file := c.Param("file")
+
+	snapshot, err := r.Snapshot(name)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if !repo.ValidArch(arch) || strings.HasPrefix(file, ".") {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	serveFile(c, r, path.Join(r.SnapshotPathDeep(snapshot.Name, arch), file))
+}
+
+func GetSnapshots(c *gin.Context) {
+	r := session.Repo(c)
+
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		log.Errorf("failed to list snapshots of repo '%s/%s': %s", r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshots)
+}
+
+func PostSnapshot(c *gin.Context) {
+	r := session.Repo(c)
+
+	in := struct {
+		Name *string `json:"name,omitempty"`
+	}{}
+
+	// the body is optional.
+	if c.Request.ContentLength > 0 {
+		err := c.BindJSON(&in)
+		if err != nil {
+			log.Errorf("failed to parse request body: %s", err)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+
+	if in.Name == nil {
+		name := time.Now().UTC().Format(repo.SnapshotDateFormat)
+		in.Name = &name
+	}
+
+	if !repo.ValidSnapshotName(*in.Name) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := r.CreateSnapshot(*in.Name)
+	if err != nil {
+		if err == repo.ErrSnapshotExists {
+			c.AbortWithStatus(http.StatusConflict)
+			return
+		}
+
+		log.Errorf("failed to create snapshot '%s' of repo '%s/%s': %s", *in.Name, r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+func DeleteSnapshot(c *gin.Context) {
+	r := session.Repo(c)
+	name := c.Param("snapshot")
+
+	err := r.DeleteSnapshot(name)
+	if err != nil {
+		if err == repo.ErrSnapshotNotFound {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		log.Errorf("failed to delete snapshot '%s' of repo '%s/%s': %s", name, r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
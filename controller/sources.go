@@ -0,0 +1,8 @@
+package controller
+
+// Package sources resolving the packages wanted by packages.yml. Sources
+// register themselves for the section named after them when imported.
+import (
+	_ "github.com/mikkeloscar/maze/source/aur"
+	_ "github.com/mikkeloscar/maze/source/local"
+)
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mikkeloscar/maze/router/middleware/session"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetVerify checks the dbs of a repo against the package files and lists
+// the problems found. Nothing is changed.
+func GetVerify(c *gin.Context) {
+	verify(c, false)
+}
+
+// PostVerify checks the dbs of a repo against the package files and repairs
+// the problems found.
+func PostVerify(c *gin.Context) {
+	verify(c, true)
+}
+
+func verify(c *gin.Context, repair bool) {
+	r := session.Repo(c)
+
+	problems, err := r.Verify(repair)
+	if err != nil {
+		log.Errorf("failed to verify repo '%s/%s': %s", r.Owner, r.Name, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if repair && len(problems) > 0 {
+		log.Printf("Repaired %d problems in repo '%s/%s'", len(problems), r.Owner, r.Name)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"problems": problems,
+	})
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,7 +13,9 @@ import (
 	"github.com/mikkeloscar/maze/repo"
 	"github.com/mikkeloscar/maze/router"
 	"github.com/mikkeloscar/maze/router/middleware/context"
+	"github.com/mikkeloscar/maze/snapshot"
 	"github.com/mikkeloscar/maze/store/datastore"
+	"github.com/mikkeloscar/maze/upload"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -38,12 +41,28 @@ func main() {
 		log.Fatalf("repo storage error: %s", err)
 	}
 
-	log.Printf("using repo storage path: %s", repo.RepoStorage)
+	log.Printf("using repo storage path: %s (%T)", repo.RepoStorage, repo.DefaultStorage)
+	log.Printf("using upload storage path: %s", repo.UploadStorage)
+	log.Printf("using lock storage path: %s", repo.LockStorage)
+
+	repo.LoadSyncDBs()
+	if len(repo.SyncDBs) > 0 {
+		log.Printf("using sync dbs: %s", strings.Join(repo.SyncDBs, ", "))
+	}
 
 	ctxStore, err := datastore.Load()
 	if err != nil {
 		log.Fatalf("failed to load datastore: %s", err)
 	}
+
+	if flag.NArg() > 0 {
+		err = runCommand(ctxStore, flag.Args())
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	ctxRemote := remote.Load()
 
 	middleware := []gin.HandlerFunc{
@@ -64,6 +83,16 @@ func main() {
 		middleware = append(middleware, context.SetState(state))
 	}
 
+	snapshots := snapshot.Scheduler{
+		Store: ctxStore,
+	}
+	go snapshots.Run()
+
+	uploads := upload.Janitor{
+		Store: ctxStore,
+	}
+	go uploads.Run()
+
 	// setup the server and start listening
 	handler := router.Load(middleware...)
 
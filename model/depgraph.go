@@ -0,0 +1,30 @@
+package model
+
+type DepEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Kind     string `json:"kind"`
+	Depend   string `json:"depend"`
+	External bool   `json:"external"`
+}
+
+type DepGraph struct {
+	Packages []string   `json:"packages"`
+	Edges    []*DepEdge `json:"edges"`
+}
+
+type DepTree struct {
+	Package     string     `json:"package"`
+	Depend      string     `json:"depend,omitempty"`
+	External    bool       `json:"external,omitempty"`
+	Repeated    bool       `json:"repeated,omitempty"`
+	Depends     []*DepTree `json:"depends,omitempty"`
+	MakeDepends []*DepTree `json:"makedepends,omitempty"`
+	OptDepends  []*DepTree `json:"optdepends,omitempty"`
+}
+
+type ReverseDeps struct {
+	Depends     []string `json:"depends"`
+	MakeDepends []string `json:"makedepends"`
+	OptDepends  []string `json:"optdepends"`
+}
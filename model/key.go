@@ -0,0 +1,10 @@
+package model
+
+type Key struct {
+	ID          int64  `json:"id"          meddler:"id,pk"`
+	RepoID      int64  `json:"-"           meddler:"repo_id"`
+	KeyID       string `json:"key_id"      meddler:"key_id"`
+	Fingerprint string `json:"fingerprint" meddler:"fingerprint"`
+	Identity    string `json:"identity"    meddler:"identity"`
+	Armored     string `json:"armored"     meddler:"armored"`
+}
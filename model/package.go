@@ -3,22 +3,52 @@ package model
 import "time"
 
 type Package struct {
-	FileName    string    `json:"filename"`
-	Name        string    `json:"name"`
-	Base        string    `json:"base"`
-	Version     string    `json:"version"`
-	Desc        string    `json:"desc"`
-	CSize       string    `json:"csize"`
-	ISize       string    `json:"isize"`
-	MD5Sum      string    `json:"md5sum"`
-	SHA256Sum   string    `json:"sha256sum"`
-	URL         string    `json:"url"`
-	License     string    `json:"license"`
-	Arch        string    `json:"arch"`
-	BuildDate   time.Time `json:"build_date"`
-	Packager    string    `json:"packager"`
-	Depends     []string  `json:"depends"`
-	OptDepends  []string  `json:"optdepends"`
-	MakeDepends []string  `json:"makedpends"`
-	Files       []string  `json:"-"`
+	FileName     string    `json:"filename"`
+	Name         string    `json:"name"`
+	Base         string    `json:"base"`
+	Version      string    `json:"version"`
+	Desc         string    `json:"desc"`
+	Groups       []string  `json:"groups"`
+	CSize        string    `json:"csize"`
+	ISize        string    `json:"isize"`
+	MD5Sum       string    `json:"md5sum"`
+	SHA256Sum    string    `json:"sha256sum"`
+	PGPSig       string    `json:"pgpsig"`
+	URL          string    `json:"url"`
+	License      []string  `json:"license"`
+	Arch         string    `json:"arch"`
+	BuildDate    time.Time `json:"build_date"`
+	Packager     string    `json:"packager"`
+	Replaces     []string  `json:"replaces"`
+	Conflicts    []string  `json:"conflicts"`
+	Provides     []string  `json:"provides"`
+	Depends      []string  `json:"depends"`
+	OptDepends   []string  `json:"optdepends"`
+	MakeDepends  []string  `json:"makedpends"`
+	CheckDepends []string  `json:"checkdepends"`
+	XData        []string  `json:"xdata"`
+	Files        []string  `json:"-"`
+}
+
+type PackageVersion struct {
+	Version  string `json:"version"`
+	FileName string `json:"filename"`
+	Current  bool   `json:"current"`
+}
+
+type FileOwner struct {
+	Owner   string `json:"owner"`
+	Repo    string `json:"repo"`
+	Arch    string `json:"arch"`
+	Package string `json:"package"`
+	Version string `json:"version"`
+	File    string `json:"file"`
+}
+
+type SearchResult struct {
+	Owner   string   `json:"owner"`
+	Repo    string   `json:"repo"`
+	Arch    string   `json:"arch"`
+	Score   int      `json:"score"`
+	Package *Package `json:"package"`
 }
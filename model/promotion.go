@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type Promotion struct {
+	ID           int64     `json:"id"             meddler:"id,pk"`
+	SourceRepoID int64     `json:"-"              meddler:"source_repo_id"`
+	TargetRepoID int64     `json:"-"              meddler:"target_repo_id"`
+	Source       string    `json:"source"         meddler:"source"`
+	Target       string    `json:"target"         meddler:"target"`
+	Package      string    `json:"package"        meddler:"package"`
+	Version      string    `json:"version"        meddler:"version"`
+	Move         bool      `json:"move"           meddler:"move"`
+	UserID       int64     `json:"-"              meddler:"user_id"`
+	User         string    `json:"user"           meddler:"user"`
+	Created      time.Time `json:"created"        meddler:"created,utctime"`
+}
@@ -3,15 +3,29 @@ package model
 import "time"
 
 type Repo struct {
-	ID           int64     `json:"id"            meddler:"id,pk"`
-	UserID       int64     `json:"-"             meddler:"user_id"`
-	Private      bool      `json:"private"       meddler:"private"`
-	Owner        string    `json:"owner"         meddler:"owner"`
-	Name         string    `json:"name"          meddler:"name"`
-	SourceOwner  string    `json:"source_owner"  meddler:"source_owner"`
-	SourceName   string    `json:"source_name"   meddler:"source_name"`
-	SourceBranch string    `json:"source_branch" meddler:"source_branch"`
-	BuildBranch  string    `json:"build_branch"  meddler:"build_branch"`
-	Hash         string    `json:"-"             meddler:"hash"`
-	LastCheck    time.Time `json:"last_check"    meddler:"last_check,utctime"`
+	ID                int64     `json:"id"                 meddler:"id,pk"`
+	UserID            int64     `json:"-"                  meddler:"user_id"`
+	Private           bool      `json:"private"            meddler:"private"`
+	Owner             string    `json:"owner"              meddler:"owner"`
+	Name              string    `json:"name"               meddler:"name"`
+	SourceOwner       string    `json:"source_owner"       meddler:"source_owner"`
+	SourceName        string    `json:"source_name"        meddler:"source_name"`
+	SourceBranch      string    `json:"source_branch"      meddler:"source_branch"`
+	BuildBranch       string    `json:"build_branch"       meddler:"build_branch"`
+	Archs             []string  `json:"archs"              meddler:"archs,json"`
+	HistorySize       int       `json:"history_size"       meddler:"history_size"`
+	SnapshotDaily     bool      `json:"snapshot_daily"     meddler:"snapshot_daily"`
+	SnapshotRetention int       `json:"snapshot_retention" meddler:"snapshot_retention"`
+	CheckDeps         bool      `json:"check_deps"         meddler:"check_deps"`
+	RequireSignatures bool      `json:"require_signatures" meddler:"require_signatures"`
+	LinkedRepos       []string  `json:"linked_repos"       meddler:"linked_repos,json"`
+	Hash              string    `json:"-"                  meddler:"hash"`
+	SigningKey        string    `json:"-"                  meddler:"signing_key"`
+	LastCheck         time.Time `json:"last_check"         meddler:"last_check,utctime"`
+}
+
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Archs   []string  `json:"archs"`
 }
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type UploadSession struct {
+	ID        int64     `json:"-"          meddler:"id,pk"`
+	SessionID string    `json:"session_id" meddler:"session_id"`
+	RepoID    int64     `json:"-"          meddler:"repo_id"`
+	UserID    int64     `json:"-"          meddler:"user_id"`
+	Created   time.Time `json:"created"    meddler:"created,utctime"`
+	Expires   time.Time `json:"expires"    meddler:"expires,utctime"`
+}
+
+type UploadFile struct {
+	ID        int64     `json:"-"        meddler:"id,pk"`
+	SessionID int64     `json:"-"        meddler:"session_id"`
+	Path      string    `json:"-"        meddler:"path"`
+	Package   *Package  `json:"package"  meddler:"package,json"`
+	Size      int64     `json:"size"     meddler:"size"`
+	SHA256    string    `json:"sha256"   meddler:"sha256"`
+	Complete  bool      `json:"complete" meddler:"complete"`
+	Created   time.Time `json:"created"  meddler:"created,utctime"`
+}
+
+type UploadResult struct {
+	File    string   `json:"file"`
+	Status  string   `json:"status"`
+	Reason  string   `json:"reason,omitempty"`
+	Package *Package `json:"package,omitempty"`
+}
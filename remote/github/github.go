@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base32"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 
@@ -246,3 +247,15 @@ func (g *Github) GetConfig(u *model.User, owner, repo, path string) (*pkgconfig.
 
 	return pkgconfig.ReadConfig(reader)
 }
+
+// GetFile gets the content of a file in a repo.
+func (g *Github) GetFile(u *model.User, owner, repo, path string) ([]byte, error) {
+	client := newClient(g.API, u.Token)
+	reader, err := client.Repositories.DownloadContents(context.Background(), owner, repo, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
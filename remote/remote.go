@@ -38,6 +38,32 @@ type Remote interface {
 
 	// GetConfig gets and parses the package.yml config file.
 	GetConfig(u *model.User, owner, repo, path string) (*pkgconfig.PkgConfig, error)
+
+	// GetFile gets the content of a file in a repo.
+	GetFile(u *model.User, owner, repo, path string) ([]byte, error)
+}
+
+// FileReader reads the files of a repo through a remote.
+type FileReader struct {
+	remote Remote
+	user   *model.User
+	owner  string
+	name   string
+}
+
+// NewFileReader returns a reader of the files of the named repo.
+func NewFileReader(remote Remote, u *model.User, owner, name string) *FileReader {
+	return &FileReader{
+		remote: remote,
+		user:   u,
+		owner:  owner,
+		name:   name,
+	}
+}
+
+// ReadFile reads the content of a file in the repo.
+func (f *FileReader) ReadFile(path string) ([]byte, error) {
+	return f.remote.GetFile(f.user, f.owner, f.name, path)
 }
 
 func Load() Remote {
@@ -0,0 +1,280 @@
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikkeloscar/maze/model"
+)
+
+// dbEntry is a single package entry of a repo database. The raw desc and
+// files content is kept such that entries can be written back unchanged.
+type dbEntry struct {
+	pkg   *model.Package
+	desc  []byte
+	files []byte
+}
+
+// dir returns the directory name of the entry in the database archive e.g.
+// "zlib-1.2.8-4".
+func (e *dbEntry) dir() string {
+	return e.pkg.Name + "-" + e.pkg.Version
+}
+
+// formatEntry formats a desc field the same way as repo-add. Fields without
+// values are left out.
+func formatEntry(buf *bytes.Buffer, field string, values ...string) {
+	if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+		return
+	}
+
+	fmt.Fprintf(buf, "%%%s%%\n", field)
+	for _, value := range values {
+		fmt.Fprintf(buf, "%s\n", value)
+	}
+	buf.WriteString("\n")
+}
+
+// newDBEntry creates a database entry from a package file. If a detached
+// signature is found next to the package it's included in the entry.
+func newDBEntry(st Storage, pkgPath string) (*dbEntry, error) {
+	info, files, err := readPkgFile(st, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	csize, md5sum, sha256sum, err := pkgChecksums(st, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pgpsig, err := readPkgSig(st, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var desc bytes.Buffer
+	formatEntry(&desc, "FILENAME", path.Base(pkgPath))
+	formatEntry(&desc, "NAME", info.name)
+	formatEntry(&desc, "BASE", info.base)
+	formatEntry(&desc, "VERSION", info.version)
+	formatEntry(&desc, "DESC", info.desc)
+	formatEntry(&desc, "GROUPS", info.groups...)
+	formatEntry(&desc, "CSIZE", fmt.Sprintf("%d", csize))
+	formatEntry(&desc, "ISIZE", info.size)
+	formatEntry(&desc, "MD5SUM", md5sum)
+	formatEntry(&desc, "SHA256SUM", sha256sum)
+	formatEntry(&desc, "PGPSIG", pgpsig)
+	formatEntry(&desc, "URL", info.url)
+	formatEntry(&desc, "LICENSE", info.licenses...)
+	formatEntry(&desc, "ARCH", info.arch)
+	formatEntry(&desc, "BUILDDATE", info.buildDate)
+	formatEntry(&desc, "PACKAGER", info.packager)
+	formatEntry(&desc, "REPLACES", info.replaces...)
+	formatEntry(&desc, "CONFLICTS", info.conflicts...)
+	formatEntry(&desc, "PROVIDES", info.provides...)
+	formatEntry(&desc, "XDATA", info.xdata...)
+	formatEntry(&desc, "DEPENDS", info.depends...)
+	formatEntry(&desc, "OPTDEPENDS", info.optDepends...)
+	formatEntry(&desc, "MAKEDEPENDS", info.makeDepends...)
+	formatEntry(&desc, "CHECKDEPENDS", info.checkDepends...)
+
+	var filesBuf bytes.Buffer
+	filesBuf.WriteString("%FILES%\n")
+	for _, file := range files {
+		fmt.Fprintf(&filesBuf, "%s\n", file)
+	}
+
+	entry := &dbEntry{
+		pkg:   &model.Package{},
+		desc:  desc.Bytes(),
+		files: filesBuf.Bytes(),
+	}
+
+	err = parsePackage(bytes.NewReader(entry.desc), entry.pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// readDB reads all entries of a database archive into a map keyed by
+// package name. A database that doesn't exist is treated as empty.
+func readDB(st Storage, dbPath string) (map[string]*dbEntry, error) {
+	entries := make(map[string]*dbEntry)
+
+	f, err := st.Open(dbPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	gzf, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	tarR := tar.NewReader(gzf)
+
+	dirs := make(map[string]*dbEntry)
+
+	for {
+		header, err := tarR.Next()
+		if err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+
+			break
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dir := path.Dir(header.Name)
+		entry, ok := dirs[dir]
+		if !ok {
+			entry = &dbEntry{pkg: &model.Package{}}
+			dirs[dir] = entry
+		}
+
+		content, err := ioutil.ReadAll(tarR)
+		if err != nil {
+			return nil, err
+		}
+
+		switch path.Base(header.Name) {
+		case "desc":
+			entry.desc = content
+		case "files":
+			entry.files = content
+		}
+	}
+
+	for dir, entry := range dirs {
+		if entry.desc == nil {
+			return nil, fmt.Errorf("missing desc for entry '%s' in %s", dir, dbPath)
+		}
+
+		err := parsePackage(bytes.NewReader(entry.desc), entry.pkg)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[entry.pkg.Name] = entry
+	}
+
+	return entries, nil
+}
+
+// writeDB writes entries to a gzipped database archive. The archive is
+// stored in place atomically, such that readers never see a partially
+// written database. If files is true the file lists of the entries are
+// included.
+func writeDB(st Storage, dbPath string, entries map[string]*dbEntry, files bool) error {
+	var buf bytes.Buffer
+
+	gzw := gzip.NewWriter(&buf)
+	tarW := tar.NewWriter(gzw)
+
+	sorted := make([]*dbEntry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].dir() < sorted[j].dir()
+	})
+
+	now := time.Now()
+
+	writeFile := func(name string, content []byte) error {
+		err := tarW.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  now,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tarW.Write(content)
+		return err
+	}
+
+	for _, entry := range sorted {
+		err := tarW.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     entry.dir() + "/",
+			Mode:     0755,
+			ModTime:  now,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = writeFile(entry.dir()+"/desc", entry.desc)
+		if err != nil {
+			return err
+		}
+
+		if files && entry.files != nil {
+			err = writeFile(entry.dir()+"/files", entry.files)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := tarW.Close()
+	if err != nil {
+		return err
+	}
+
+	err = gzw.Close()
+	if err != nil {
+		return err
+	}
+
+	return st.Put(dbPath, &buf)
+}
+
+// linkDBs makes sure the '<name>.db' and '<name>.files' symlinks, and the
+// links to their signatures if signed is true, exist for an arch.
+func (r *Repo) linkDBs(arch string, signed bool) error {
+	for _, db := range []string{r.DB(arch), r.FilesDB(arch)} {
+		link := strings.TrimSuffix(db, ".tar.gz")
+
+		// as links are copies on some storages, the db link is
+		// updated right after the signature link.
+		if signed {
+			err := r.storage.Link(path.Base(db)+".sig", link+".sig")
+			if err != nil {
+				return err
+			}
+		}
+
+		err := r.storage.Link(path.Base(db), link)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that db entries match what repo-add produces.
+func TestNewDBEntry(t *testing.T) {
+	entries, err := readDB(localFS, repo1.FilesDB("x86_64"))
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, entries, 1, "should have length 1")
+
+	expected, ok := entries["ca-certificates"]
+	assert.True(t, ok, "should be true")
+
+	entry, err := newDBEntry(localFS, path.Join(repo1.PathDeep("x86_64"), expected.pkg.FileName))
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, string(expected.desc), string(entry.desc), "should be equal")
+	assert.Equal(t, string(expected.files), string(entry.files), "should be equal")
+	assert.Equal(t, "ca-certificates-20150402-1", entry.dir(), "should be equal")
+}
+
+// Test writing and reading back a db.
+func TestWriteDB(t *testing.T) {
+	err := repo2.InitDir()
+	assert.NoError(t, err, "should not fail")
+
+	err = repo2.InitEmptyDBs()
+	assert.NoError(t, err, "should not fail")
+
+	entries, err := readDB(localFS, repo2.FilesDB("x86_64"))
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, entries, 0, "should have length 0")
+
+	entries, err = readDB(localFS, repo1.FilesDB("x86_64"))
+	assert.NoError(t, err, "should not fail")
+
+	txn := repo2.begin()
+	txn.dbs["x86_64"] = entries
+	err = txn.commit()
+	assert.NoError(t, err, "should not fail")
+
+	pkgs, err := repo2.Packages("x86_64", true)
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, pkgs, 1, "should have length 1")
+
+	dbEntries, err := readDB(localFS, repo2.DB("x86_64"))
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, dbEntries, 1, "should have length 1")
+	assert.Nil(t, dbEntries["ca-certificates"].files, "should be nil")
+
+	// clean
+	err = repo2.ClearPath()
+	assert.NoError(t, err, "should not fail")
+}
+
+// Test that xdata of the .PKGINFO is written to the desc.
+func TestNewDBEntryXData(t *testing.T) {
+	pkg := writeTestPkg(t, t.TempDir(), "foo", "1.0-1", "x86_64", "xdata = pkgtype=pkg")
+
+	entry, err := newDBEntry(localFS, pkg)
+	assert.NoError(t, err, "should not fail")
+	assert.Contains(t, string(entry.desc), "%XDATA%\npkgtype=pkg\n", "should contain xdata")
+	assert.Equal(t, []string{"pkgtype=pkg"}, entry.pkg.XData, "should be equal")
+}
@@ -0,0 +1,220 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mikkeloscar/gopkgbuild"
+	"github.com/mikkeloscar/maze/model"
+)
+
+// SyncDBs is a list of upstream sync dbs used for resolving dependencies of
+// uploaded packages. '$arch' in a path is replaced by the arch being checked.
+var SyncDBs []string
+
+// LoadSyncDBs reads the comma separated list of sync db paths set by
+// SYNC_DBS e.g. "/var/lib/pacman/sync/core.db,/var/lib/pacman/sync/extra.db".
+func LoadSyncDBs() {
+	SyncDBs = nil
+
+	for _, db := range strings.Split(os.Getenv("SYNC_DBS"), ",") {
+		db = strings.TrimSpace(db)
+		if db != "" {
+			SyncDBs = append(SyncDBs, db)
+		}
+	}
+}
+
+// MissingDep describes a dependency of a package which can't be satisfied.
+type MissingDep struct {
+	Package string `json:"package"`
+	Arch    string `json:"arch"`
+	Depend  string `json:"depend"`
+}
+
+// DepsError is returned when dependencies of packages can't be satisfied.
+type DepsError struct {
+	Missing []*MissingDep
+}
+
+func (e *DepsError) Error() string {
+	deps := make([]string, 0, len(e.Missing))
+	for _, m := range e.Missing {
+		deps = append(deps, fmt.Sprintf("%s (%s): %s", m.Package, m.Arch, m.Depend))
+	}
+	return "unsatisfied dependencies: " + strings.Join(deps, ", ")
+}
+
+// provider is a package name or provide with an optional version.
+type provider struct {
+	pkg     string
+	version *pkgbuild.CompleteVersion
+}
+
+// providers maps package names and provides to their providers.
+type providers map[string][]provider
+
+// add adds a package and everything it provides.
+func (p providers) add(pkg *model.Package) {
+	version, _ := pkgbuild.NewCompleteVersion(pkg.Version)
+	p[pkg.Name] = append(p[pkg.Name], provider{pkg.Name, version})
+
+	for _, provide := range pkg.Provides {
+		name := provide
+		var version *pkgbuild.CompleteVersion
+
+		if i := strings.Index(provide, "="); i >= 0 {
+			name = provide[:i]
+			version, _ = pkgbuild.NewCompleteVersion(provide[i+1:])
+		}
+
+		p[name] = append(p[name], provider{pkg.Name, version})
+	}
+}
+
+// satisfies returns true if a dependency is satisfied by one of the
+// providers. Unversioned provides only satisfy unversioned dependencies.
+func (p providers) satisfies(dep *pkgbuild.Dependency) bool {
+	return len(p.resolve(dep)) > 0
+}
+
+// resolve returns the names of the packages satisfying a dependency. A
+// package with the name of the dependency is listed first.
+func (p providers) resolve(dep *pkgbuild.Dependency) []string {
+	var pkgs []string
+
+	for _, prov := range p[dep.Name] {
+		if (dep.MinVer != nil || dep.MaxVer != nil) && (prov.version == nil || !prov.version.Satisfies(dep)) {
+			continue
+		}
+
+		if prov.pkg == dep.Name {
+			pkgs = append([]string{prov.pkg}, pkgs...)
+		} else {
+			pkgs = append(pkgs, prov.pkg)
+		}
+	}
+
+	return pkgs
+}
+
+// VerifyDeps checks that the depends of the package files can be satisfied by
+// the packages themselves, the packages of the repo which aren't replaced by
+// the package files, the linked repos and the sync dbs. A *DepsError listing
+// all missing dependencies is returned if any can't be satisfied. Signature
+// files in the list are skipped.
+func (r *Repo) VerifyDeps(pkgPaths []string, linked []*Repo) error {
+	archPkgs := make(map[string][]*model.Package)
+
+	for _, pkgPath := range pkgPaths {
+		if strings.HasSuffix(pkgPath, ".sig") {
+			continue
+		}
+
+		info, _, err := readPkgFile(localFS, pkgPath)
+		if err != nil {
+			return err
+		}
+
+		pkg := &model.Package{
+			Name:     info.name,
+			Version:  info.version,
+			Arch:     info.arch,
+			Provides: info.provides,
+			Depends:  info.depends,
+		}
+
+		archs := []string{pkg.Arch}
+		if pkg.Arch == "any" {
+			archs = r.Archs
+		}
+
+		for _, arch := range archs {
+			archPkgs[arch] = append(archPkgs[arch], pkg)
+		}
+	}
+
+	archs := make([]string, 0, len(archPkgs))
+	for arch := range archPkgs {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+
+	var missing []*MissingDep
+
+	for _, arch := range archs {
+		pkgs := archPkgs[arch]
+		provs, err := r.providers(arch, pkgs, linked)
+		if err != nil {
+			return err
+		}
+
+		for _, pkg := range pkgs {
+			for _, depend := range pkg.Depends {
+				deps, err := pkgbuild.ParseDeps([]string{depend})
+				if err != nil || len(deps) == 0 || !provs.satisfies(deps[0]) {
+					missing = append(missing, &MissingDep{
+						Package: pkg.Name,
+						Arch:    arch,
+						Depend:  depend,
+					})
+				}
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return &DepsError{Missing: missing}
+	}
+
+	return nil
+}
+
+// providers collects the providers of an arch from the packages, the repo,
+// the linked repos and the sync dbs.
+func (r *Repo) providers(arch string, pkgs []*model.Package, linked []*Repo) (providers, error) {
+	provs := make(providers)
+	replaced := make(map[string]struct{}, len(pkgs))
+
+	for _, pkg := range pkgs {
+		provs.add(pkg)
+		replaced[pkg.Name] = struct{}{}
+	}
+
+	idx, err := r.index(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, entry := range idx.entries {
+		if _, ok := replaced[name]; !ok {
+			provs.add(entry.pkg)
+		}
+	}
+
+	for _, l := range linked {
+		idx, err := l.index(arch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range idx.entries {
+			provs.add(entry.pkg)
+		}
+	}
+
+	for _, db := range SyncDBs {
+		idx, err := loadIndex(localFS, strings.Replace(db, "$arch", arch, -1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range idx.entries {
+			provs.add(entry.pkg)
+		}
+	}
+
+	return provs, nil
+}
@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test resolving dependencies of packages against the repo, linked repos and
+// sync dbs.
+func TestVerifyDeps(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "deps"}, repoStorage)
+	linked := NewRepo(&model.Repo{Name: "depslinked"}, repoStorage)
+	sync := NewRepo(&model.Repo{Name: "depssync"}, repoStorage)
+
+	for _, repo := range []*Repo{r, linked, sync} {
+		err := repo.InitDir()
+		assert.NoError(t, err, "should not fail")
+		defer repo.ClearPath()
+	}
+
+	libbar := writeTestPkg(t, r.Path(), "libbar", "2.0-1", "x86_64", "provides = libbar.so=2-64")
+	err := r.Add([]string{libbar})
+	assert.NoError(t, err, "should not fail")
+
+	baz := writeTestPkg(t, linked.Path(), "baz", "1.0-1", "any")
+	err = linked.Add([]string{baz})
+	assert.NoError(t, err, "should not fail")
+
+	qux := writeTestPkg(t, sync.Path(), "qux", "3.1-2", "x86_64", "provides = quux")
+	err = sync.Add([]string{qux})
+	assert.NoError(t, err, "should not fail")
+
+	SyncDBs = []string{strings.Replace(sync.DB("x86_64"), "x86_64", "$arch", 1)}
+	defer func() { SyncDBs = nil }()
+
+	foo := writeTestPkg(t, r.Path(), "foo", "1.0-1", "x86_64",
+		"depend = libbar.so=2-64",
+		"depend = baz",
+		"depend = qux>=3",
+		"depend = quux",
+		"depend = foo-data=1.0")
+	fooData := writeTestPkg(t, r.Path(), "foo-data", "1.0-1", "any")
+
+	err = r.VerifyDeps([]string{foo, fooData}, []*Repo{linked})
+	assert.NoError(t, err, "should not fail")
+
+	// unversioned provides don't satisfy versioned depends.
+	foo = writeTestPkg(t, r.Path(), "foo", "1.0-2", "x86_64",
+		"depend = libbar>2.0",
+		"depend = quux>1",
+		"depend = baz")
+
+	err = r.VerifyDeps([]string{foo}, nil)
+	assert.IsType(t, &DepsError{}, err, "should be a deps error")
+	missing := err.(*DepsError).Missing
+	assert.Len(t, missing, 3, "should have length 3")
+	assert.Equal(t, &MissingDep{Package: "foo", Arch: "x86_64", Depend: "libbar>2.0"}, missing[0], "should be equal")
+	assert.Equal(t, "quux>1", missing[1].Depend, "should be equal")
+	assert.Equal(t, "baz", missing[2].Depend, "should be equal")
+}
@@ -0,0 +1,178 @@
+package repo
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mikkeloscar/maze/model"
+)
+
+// File query modes.
+const (
+	// FileQueryPath matches an exact file path.
+	FileQueryPath = "path"
+	// FileQueryName matches the base name of files.
+	FileQueryName = "name"
+	// FileQueryGlob matches a shell pattern against the file path, or
+	// against the base name if the pattern doesn't contain a '/'.
+	FileQueryGlob = "glob"
+	// FileQueryRegex matches a regular expression against the file path.
+	FileQueryRegex = "regex"
+)
+
+// FileQuery is a compiled query for package files.
+type FileQuery struct {
+	path  string
+	name  string
+	match func(file string) bool
+}
+
+// NewFileQuery compiles a file query. If mode is empty the query is a path
+// query if it contains a '/' and a name query otherwise.
+func NewFileQuery(query, mode string) (*FileQuery, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty file query")
+	}
+
+	if mode == "" {
+		mode = FileQueryName
+		if strings.Contains(query, "/") {
+			mode = FileQueryPath
+		}
+	}
+
+	switch mode {
+	case FileQueryPath:
+		return &FileQuery{path: strings.TrimPrefix(query, "/")}, nil
+	case FileQueryName:
+		return &FileQuery{name: query}, nil
+	case FileQueryGlob:
+		if _, err := path.Match(query, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern '%s': %s", query, err)
+		}
+
+		if !strings.Contains(query, "/") {
+			return &FileQuery{match: func(file string) bool {
+				ok, _ := path.Match(query, path.Base(file))
+				return ok
+			}}, nil
+		}
+
+		query = strings.TrimPrefix(query, "/")
+		return &FileQuery{match: func(file string) bool {
+			ok, _ := path.Match(query, file)
+			return ok
+		}}, nil
+	case FileQueryRegex:
+		re, err := regexp.Compile(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex '%s': %s", query, err)
+		}
+
+		return &FileQuery{match: func(file string) bool {
+			return re.MatchString("/" + file)
+		}}, nil
+	default:
+		return nil, fmt.Errorf("invalid file query mode '%s'", mode)
+	}
+}
+
+// fileIndex maps the files of the packages in an index to the packages
+// owning them. Directories are not indexed.
+type fileIndex struct {
+	// paths is the sorted list of all files.
+	paths  []string
+	owners map[string][]*dbEntry
+	names  map[string][]string
+}
+
+// fileIndex returns the file index of the packages in the index. It's built
+// on first use.
+func (idx *index) fileIndex() *fileIndex {
+	idx.filesOnce.Do(func() {
+		files := &fileIndex{
+			owners: make(map[string][]*dbEntry),
+			names:  make(map[string][]string),
+		}
+
+		for _, entry := range idx.sorted {
+			for _, file := range strings.Split(string(entry.files), "\n") {
+				if file == "" || file == "%FILES%" || strings.HasSuffix(file, "/") {
+					continue
+				}
+
+				if _, ok := files.owners[file]; !ok {
+					files.paths = append(files.paths, file)
+					name := path.Base(file)
+					files.names[name] = append(files.names[name], file)
+				}
+				files.owners[file] = append(files.owners[file], entry)
+			}
+		}
+
+		sort.Strings(files.paths)
+		for _, paths := range files.names {
+			sort.Strings(paths)
+		}
+
+		idx.files = files
+	})
+
+	return idx.files
+}
+
+// search returns the files matching the query in sorted order.
+func (f *fileIndex) search(q *FileQuery) []string {
+	switch {
+	case q.path != "":
+		if _, ok := f.owners[q.path]; ok {
+			return []string{q.path}
+		}
+		return nil
+	case q.name != "":
+		return f.names[q.name]
+	default:
+		var matches []string
+		for _, file := range f.paths {
+			if q.match(file) {
+				matches = append(matches, file)
+			}
+		}
+		return matches
+	}
+}
+
+// SearchFiles returns the packages of an arch owning files matching the
+// query. At most limit results are returned if limit is greater than 0.
+func (r *Repo) SearchFiles(arch string, q *FileQuery, limit int) ([]*model.FileOwner, error) {
+	idx, err := r.index(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	files := idx.fileIndex()
+
+	owners := []*model.FileOwner{}
+
+	for _, file := range files.search(q) {
+		for _, entry := range files.owners[file] {
+			if limit > 0 && len(owners) >= limit {
+				return owners, nil
+			}
+
+			owners = append(owners, &model.FileOwner{
+				Owner:   r.Owner,
+				Repo:    r.Name,
+				Arch:    arch,
+				Package: entry.pkg.Name,
+				Version: entry.pkg.Version,
+				File:    "/" + file,
+			})
+		}
+	}
+
+	return owners, nil
+}
@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test searching the owners of package files.
+func TestSearchFiles(t *testing.T) {
+	r := NewRepo(&model.Repo{Owner: "owner", Name: "files"}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	err = r.Add([]string{
+		writeTestPkg(t, r.Path(), "foo", "1.0-1", "x86_64"),
+		writeTestPkg(t, r.Path(), "bar", "2.0-1", "any"),
+	})
+	assert.NoError(t, err, "should not fail")
+
+	for _, tc := range []struct {
+		query string
+		mode  string
+		files []string
+	}{
+		{"/usr/share/foo/README", "", []string{"/usr/share/foo/README"}},
+		{"usr/share/foo/README", FileQueryPath, []string{"/usr/share/foo/README"}},
+		{"/usr/share/foo", "", nil},
+		{"README", "", []string{"/usr/share/bar/README", "/usr/share/foo/README"}},
+		{"READ*", FileQueryGlob, []string{"/usr/share/bar/README", "/usr/share/foo/README"}},
+		{"/usr/share/b*/*", FileQueryGlob, []string{"/usr/share/bar/README"}},
+		{"^/usr/.*/foo/", FileQueryRegex, []string{"/usr/share/foo/README"}},
+	} {
+		q, err := NewFileQuery(tc.query, tc.mode)
+		assert.NoError(t, err, "should not fail")
+
+		owners, err := r.SearchFiles("x86_64", q, 0)
+		assert.NoError(t, err, "should not fail")
+
+		var files []string
+		for _, owner := range owners {
+			files = append(files, owner.File)
+		}
+		assert.Equal(t, tc.files, files, "should be equal for %s", tc.query)
+	}
+
+	q, err := NewFileQuery("README", "")
+	assert.NoError(t, err, "should not fail")
+
+	owners, err := r.SearchFiles("x86_64", q, 1)
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, owners, 1, "should be limited")
+	assert.Equal(t, &model.FileOwner{
+		Owner:   "owner",
+		Repo:    "files",
+		Arch:    "x86_64",
+		Package: "bar",
+		Version: "2.0-1",
+		File:    "/usr/share/bar/README",
+	}, owners[0], "should be equal")
+
+	_, err = NewFileQuery("[", FileQueryGlob)
+	assert.Error(t, err, "should fail")
+
+	_, err = NewFileQuery("(", FileQueryRegex)
+	assert.Error(t, err, "should fail")
+
+	_, err = NewFileQuery("foo", "fuzzy")
+	assert.Error(t, err, "should fail")
+}
@@ -0,0 +1,238 @@
+package repo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mikkeloscar/gopkgbuild"
+	"github.com/mikkeloscar/maze/common/util"
+	"github.com/mikkeloscar/maze/model"
+)
+
+// Dependency kinds.
+const (
+	DepKindDepends     = "depends"
+	DepKindMakeDepends = "makedepends"
+	DepKindOptDepends  = "optdepends"
+)
+
+// DepGraph returns the dependency graph of the packages of an arch.
+// Dependencies are resolved by name and provides within the repo.
+// Dependencies which can't be resolved are included as external edges to the
+// dependency name.
+func (r *Repo) DepGraph(arch string) (*model.DepGraph, error) {
+	idx, err := r.index(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	return depGraph(idx.sorted), nil
+}
+
+func depGraph(entries []*dbEntry) *model.DepGraph {
+	provs := make(providers)
+	for _, entry := range entries {
+		provs.add(entry.pkg)
+	}
+
+	graph := &model.DepGraph{
+		Packages: make([]string, 0, len(entries)),
+		Edges:    []*model.DepEdge{},
+	}
+
+	for _, entry := range entries {
+		pkg := entry.pkg
+		graph.Packages = append(graph.Packages, pkg.Name)
+
+		for _, kind := range []struct {
+			name string
+			deps []string
+		}{
+			{DepKindDepends, pkg.Depends},
+			{DepKindMakeDepends, pkg.MakeDepends},
+			{DepKindOptDepends, pkg.OptDepends},
+		} {
+			for _, depend := range kind.deps {
+				depend = stripDepDesc(depend)
+
+				edge := &model.DepEdge{
+					From:   pkg.Name,
+					To:     depName(depend),
+					Kind:   kind.name,
+					Depend: depend,
+				}
+
+				deps, err := pkgbuild.ParseDeps([]string{depend})
+				var resolved []string
+				if err == nil && len(deps) > 0 {
+					resolved = provs.resolve(deps[0])
+				}
+
+				if len(resolved) > 0 {
+					edge.To = resolved[0]
+				} else {
+					edge.External = true
+				}
+
+				graph.Edges = append(graph.Edges, edge)
+			}
+		}
+	}
+
+	sort.Strings(graph.Packages)
+
+	return graph
+}
+
+// stripDepDesc strips the description from an optional dependency e.g.
+// "foo: for foo support".
+func stripDepDesc(depend string) string {
+	if i := strings.Index(depend, ": "); i >= 0 {
+		return depend[:i]
+	}
+	return strings.TrimSuffix(depend, ":")
+}
+
+// DepTree returns the forward dependency tree of a package. Each package is
+// expanded only once, later occurrences are marked as repeated. nil is
+// returned if the package isn't in the repo.
+func (r *Repo) DepTree(name, arch string) (*model.DepTree, error) {
+	idx, err := r.index(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := idx.entries[name]; !ok {
+		return nil, nil
+	}
+
+	graph := depGraph(idx.sorted)
+
+	edges := make(map[string][]*model.DepEdge)
+	for _, edge := range graph.Edges {
+		edges[edge.From] = append(edges[edge.From], edge)
+	}
+
+	seen := make(map[string]struct{})
+
+	var expand func(node *model.DepTree)
+	expand = func(node *model.DepTree) {
+		seen[node.Package] = struct{}{}
+
+		for _, edge := range edges[node.Package] {
+			child := &model.DepTree{
+				Package:  edge.To,
+				Depend:   edge.Depend,
+				External: edge.External,
+			}
+
+			if !edge.External {
+				if _, ok := seen[edge.To]; ok {
+					child.Repeated = true
+				} else {
+					expand(child)
+				}
+			}
+
+			switch edge.Kind {
+			case DepKindDepends:
+				node.Depends = append(node.Depends, child)
+			case DepKindMakeDepends:
+				node.MakeDepends = append(node.MakeDepends, child)
+			case DepKindOptDepends:
+				node.OptDepends = append(node.OptDepends, child)
+			}
+		}
+	}
+
+	tree := &model.DepTree{Package: name}
+	expand(tree)
+
+	return tree, nil
+}
+
+// ReverseDeps returns the packages of an arch depending directly on a
+// package. nil is returned if the package isn't in the repo.
+func (r *Repo) ReverseDeps(name, arch string) (*model.ReverseDeps, error) {
+	idx, err := r.index(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := idx.entries[name]; !ok {
+		return nil, nil
+	}
+
+	rdeps := &model.ReverseDeps{
+		Depends:     []string{},
+		MakeDepends: []string{},
+		OptDepends:  []string{},
+	}
+
+	for _, edge := range depGraph(idx.sorted).Edges {
+		if edge.External || edge.To != name {
+			continue
+		}
+
+		switch edge.Kind {
+		case DepKindDepends:
+			if !util.StrContains(edge.From, rdeps.Depends) {
+				rdeps.Depends = append(rdeps.Depends, edge.From)
+			}
+		case DepKindMakeDepends:
+			if !util.StrContains(edge.From, rdeps.MakeDepends) {
+				rdeps.MakeDepends = append(rdeps.MakeDepends, edge.From)
+			}
+		case DepKindOptDepends:
+			if !util.StrContains(edge.From, rdeps.OptDepends) {
+				rdeps.OptDepends = append(rdeps.OptDepends, edge.From)
+			}
+		}
+	}
+
+	sort.Strings(rdeps.Depends)
+	sort.Strings(rdeps.MakeDepends)
+	sort.Strings(rdeps.OptDepends)
+
+	return rdeps, nil
+}
+
+// WriteDOT writes a dependency graph in the Graphviz DOT format. Make
+// dependencies are drawn dashed, optional dependencies dotted and external
+// dependencies as boxes.
+func WriteDOT(w io.Writer, name string, graph *model.DepGraph) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "digraph %q {\n", name)
+
+	for _, pkg := range graph.Packages {
+		fmt.Fprintf(bw, "\t%q;\n", pkg)
+	}
+
+	external := make(map[string]struct{})
+	for _, edge := range graph.Edges {
+		if _, ok := external[edge.To]; edge.External && !ok {
+			external[edge.To] = struct{}{}
+			fmt.Fprintf(bw, "\t%q [shape=box, style=dashed];\n", edge.To)
+		}
+	}
+
+	for _, edge := range graph.Edges {
+		attrs := fmt.Sprintf("label=%q", edge.Kind)
+		switch edge.Kind {
+		case DepKindMakeDepends:
+			attrs += ", style=dashed"
+		case DepKindOptDepends:
+			attrs += ", style=dotted"
+		}
+
+		fmt.Fprintf(bw, "\t%q -> %q [%s];\n", edge.From, edge.To, attrs)
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
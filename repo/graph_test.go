@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test dependency trees, reverse dependencies and graphs.
+func TestDepGraph(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "graph"}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	err = r.Add([]string{
+		writeTestPkg(t, r.Path(), "app", "1.0-1", "x86_64",
+			"depend = libfoo.so", "makedepend = cmake", "optdepend = extra: for extras"),
+		writeTestPkg(t, r.Path(), "lib", "1.0-1", "x86_64", "provides = libfoo.so", "depend = base"),
+		writeTestPkg(t, r.Path(), "extra", "1.0-1", "any", "depend = base>=1.0"),
+		writeTestPkg(t, r.Path(), "base", "1.0-1", "x86_64"),
+	})
+	assert.NoError(t, err, "should not fail")
+
+	tree, err := r.DepTree("app", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, &model.DepTree{
+		Package: "app",
+		Depends: []*model.DepTree{
+			{
+				Package: "lib",
+				Depend:  "libfoo.so",
+				Depends: []*model.DepTree{{Package: "base", Depend: "base"}},
+			},
+		},
+		MakeDepends: []*model.DepTree{{Package: "cmake", Depend: "cmake", External: true}},
+		OptDepends: []*model.DepTree{
+			{
+				Package: "extra",
+				Depend:  "extra",
+				Depends: []*model.DepTree{{Package: "base", Depend: "base>=1.0", Repeated: true}},
+			},
+		},
+	}, tree, "should be equal")
+
+	tree, err = r.DepTree("missing", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Nil(t, tree, "should be nil")
+
+	rdeps, err := r.ReverseDeps("base", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, &model.ReverseDeps{
+		Depends:     []string{"extra", "lib"},
+		MakeDepends: []string{},
+		OptDepends:  []string{},
+	}, rdeps, "should be equal")
+
+	rdeps, err = r.ReverseDeps("extra", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, []string{"app"}, rdeps.OptDepends, "should be equal")
+
+	graph, err := r.DepGraph("x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, []string{"app", "base", "extra", "lib"}, graph.Packages, "should be equal")
+	assert.Len(t, graph.Edges, 5, "should be equal")
+
+	var buf bytes.Buffer
+	err = WriteDOT(&buf, "graph", graph)
+	assert.NoError(t, err, "should not fail")
+	assert.Contains(t, buf.String(), `"app" -> "lib" [label="depends"];`, "should contain edge")
+	assert.Contains(t, buf.String(), `"app" -> "cmake" [label="makedepends", style=dashed];`, "should contain edge")
+	assert.Contains(t, buf.String(), `"cmake" [shape=box, style=dashed];`, "should contain external node")
+}
@@ -0,0 +1,211 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mikkeloscar/gopkgbuild"
+	"github.com/mikkeloscar/maze/model"
+)
+
+// ErrVersionNotFound is returned when a requested package version isn't
+// available in the archive.
+var ErrVersionNotFound = errors.New("package version not found")
+
+// ArchivePath returns the path to the archive of old package versions for
+// an arch.
+func (r *Repo) ArchivePath(arch string) string {
+	return path.Join(r.Path(), "archive", arch)
+}
+
+// archiveFile moves a package file and its signature from the arch dir to
+// the archive and prunes the archive such that at most HistorySize versions
+// of the package are kept. If the repo doesn't keep a history the files are
+// removed.
+func (r *Repo) archiveFile(arch, file string) error {
+	if r.HistorySize <= 0 {
+		return r.removeFile(arch, file)
+	}
+
+	for _, f := range []string{file, file + ".sig"} {
+		err := r.storage.Rename(path.Join(r.PathDeep(arch), f), path.Join(r.ArchivePath(arch), f))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	name, _, _, err := splitFileNameVersion(file)
+	if err != nil {
+		return err
+	}
+
+	return r.pruneArchive(name, arch)
+}
+
+// archivedVersions returns the archived versions of a package sorted with
+// the newest version first.
+func (r *Repo) archivedVersions(name, arch string) ([]*model.PackageVersion, error) {
+	files, err := r.storage.List(r.ArchivePath(arch))
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*model.PackageVersion
+	parsed := make(map[string]*pkgbuild.CompleteVersion)
+
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, ".sig") {
+			continue
+		}
+
+		n, version, _, err := splitFileNameVersion(f.Name)
+		if err != nil || n != name {
+			continue
+		}
+
+		v, err := pkgbuild.NewCompleteVersion(version)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed[f.Name] = v
+		versions = append(versions, &model.PackageVersion{
+			Version:  version,
+			FileName: f.Name,
+		})
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return parsed[versions[i].FileName].Newer(parsed[versions[j].FileName])
+	})
+
+	return versions, nil
+}
+
+// pruneArchive removes the oldest archived versions of a package such that
+// at most HistorySize versions are kept.
+func (r *Repo) pruneArchive(name, arch string) error {
+	versions, err := r.archivedVersions(name, arch)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) <= r.HistorySize {
+		return nil
+	}
+
+	for _, version := range versions[r.HistorySize:] {
+		for _, f := range []string{version.FileName, version.FileName + ".sig"} {
+			err := r.storage.Remove(path.Join(r.ArchivePath(arch), f))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// History returns the versions of a package available in the repo. The
+// current version is listed first, followed by the archived versions
+// sorted with the newest version first.
+func (r *Repo) History(name, arch string) ([]*model.PackageVersion, error) {
+	unlock, err := r.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := readDB(r.storage, r.DB(arch))
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*model.PackageVersion
+
+	if entry, ok := entries[name]; ok {
+		versions = append(versions, &model.PackageVersion{
+			Version:  entry.pkg.Version,
+			FileName: entry.pkg.FileName,
+			Current:  true,
+		})
+	}
+
+	archived, err := r.archivedVersions(name, arch)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(versions, archived...), nil
+}
+
+// Rollback makes an archived version of a package the current version in
+// the repo db. The replaced version is moved to the archive. 'any' packages
+// are rolled back in all archs, such that all archs keep the same version.
+func (r *Repo) Rollback(name, arch, version string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	archived, err := r.archivedVersions(name, arch)
+	if err != nil {
+		return err
+	}
+
+	var file string
+	for _, v := range archived {
+		if v.Version == version {
+			file = v.FileName
+			break
+		}
+	}
+
+	if file == "" {
+		return ErrVersionNotFound
+	}
+
+	_, _, pkgArch, err := splitFileNameVersion(file)
+	if err != nil {
+		return err
+	}
+
+	archs := []string{arch}
+	if pkgArch == "any" {
+		for _, a := range r.Archs {
+			if a != arch {
+				archs = append(archs, a)
+			}
+		}
+	}
+
+	t := r.begin()
+
+	for _, a := range archs {
+		err := t.unarchive(a, file)
+		if os.IsNotExist(err) && a != arch {
+			// the version may have been pruned from the archive of
+			// the arch, or the arch was added later.
+			err = t.copyPkgFile(path.Join(r.PathDeep(arch), file), path.Join(r.PathDeep(a), file))
+		}
+		if err != nil {
+			t.abort()
+			return err
+		}
+
+		entry, err := newDBEntry(r.storage, path.Join(r.PathDeep(a), file))
+		if err == nil {
+			err = t.add(a, []*dbEntry{entry})
+		}
+		if err != nil {
+			t.abort()
+			return err
+		}
+	}
+
+	return t.commit()
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that replaced versions are archived and can be rolled back.
+func TestHistoryRollback(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "history", HistorySize: 2}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	for _, version := range []string{"1.0-1", "1.1-1", "1.2-1", "1.3-1"} {
+		pkg := writeTestPkg(t, r.Path(), "foo", version, "x86_64")
+		err = r.Add([]string{pkg})
+		assert.NoError(t, err, "should not fail")
+	}
+
+	versions, err := r.History("foo", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, versions, 3, "should have length 3")
+	assert.Equal(t, "1.3-1", versions[0].Version, "should be equal")
+	assert.True(t, versions[0].Current, "should be true")
+	assert.Equal(t, "1.2-1", versions[1].Version, "should be equal")
+	assert.Equal(t, "1.1-1", versions[2].Version, "should be equal")
+
+	err = r.Rollback("foo", "x86_64", "1.0-1")
+	assert.Equal(t, ErrVersionNotFound, err, "should be equal")
+
+	err = r.Rollback("foo", "x86_64", "1.1-1")
+	assert.NoError(t, err, "should not fail")
+
+	pkg, err := r.Package("foo", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, "1.1-1", pkg.Version, "should be equal")
+
+	_, err = os.Stat(path.Join(r.PathDeep("x86_64"), pkg.FileName))
+	assert.NoError(t, err, "should not fail")
+
+	versions, err = r.History("foo", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, versions, 3, "should have length 3")
+	assert.Equal(t, "1.1-1", versions[0].Version, "should be equal")
+	assert.Equal(t, "1.3-1", versions[1].Version, "should be equal")
+	assert.Equal(t, "1.2-1", versions[2].Version, "should be equal")
+
+	// removed packages are archived as well
+	err = r.Remove([]string{"foo"}, "x86_64")
+	assert.NoError(t, err, "should not fail")
+
+	versions, err = r.History("foo", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, versions, 2, "should have length 2")
+	assert.False(t, versions[0].Current, "should be false")
+}
+
+// Test that 'any' packages are rolled back in all archs.
+func TestHistoryRollbackAny(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "historyany", Archs: []string{"x86_64", "aarch64"}, HistorySize: 2}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	for _, version := range []string{"1.0-1", "1.1-1"} {
+		pkg := writeTestPkg(t, r.Path(), "foo", version, "any")
+		err = r.Add([]string{pkg})
+		assert.NoError(t, err, "should not fail")
+	}
+
+	err = r.Rollback("foo", "x86_64", "1.0-1")
+	assert.NoError(t, err, "should not fail")
+
+	for _, arch := range r.Archs {
+		pkg, err := r.Package("foo", arch, false)
+		assert.NoError(t, err, "should not fail")
+		assert.Equal(t, "1.0-1", pkg.Version, "should be equal")
+
+		_, err = os.Stat(path.Join(r.PathDeep(arch), pkg.FileName))
+		assert.NoError(t, err, "should not fail")
+
+		versions, err := r.History("foo", arch)
+		assert.NoError(t, err, "should not fail")
+		assert.Len(t, versions, 2, "should have length 2")
+		assert.Equal(t, "1.1-1", versions[1].Version, "should be equal")
+	}
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikkeloscar/maze/model"
+)
+
+// index is a parsed in-memory view of the files db of a repo arch.
+type index struct {
+	modTime time.Time
+	size    int64
+	entries map[string]*dbEntry
+	sorted  []*dbEntry
+
+	filesOnce sync.Once
+	files     *fileIndex
+}
+
+// indexes caches the parsed indexes of all repo archs and sync dbs keyed by
+// the path of the db. The cache is shared by all Repo instances.
+var indexes = struct {
+	sync.Mutex
+	m map[string]*cachedIndex
+}{m: make(map[string]*cachedIndex)}
+
+// cachedIndex holds the cached index of a single db. The lock serializes
+// loading the db so it's only read once if it changed.
+type cachedIndex struct {
+	sync.Mutex
+	idx *index
+}
+
+// index returns the parsed index of an arch. The index is (re)built if the
+// files db changed since it was last read.
+func (r *Repo) index(arch string) (*index, error) {
+	return loadIndex(r.storage, r.FilesDB(arch))
+}
+
+// loadIndex returns the parsed index of a db. The index is (re)built if the
+// db changed since it was last read.
+func loadIndex(st Storage, dbPath string) (*index, error) {
+	indexes.Lock()
+	cached, ok := indexes.m[dbPath]
+	if !ok {
+		cached = &cachedIndex{}
+		indexes.m[dbPath] = cached
+	}
+	indexes.Unlock()
+
+	cached.Lock()
+	defer cached.Unlock()
+
+	var modTime time.Time
+	var size int64
+
+	info, err := st.Stat(dbPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	} else {
+		modTime = info.ModTime
+		size = info.Size
+	}
+
+	if idx := cached.idx; idx != nil && idx.modTime.Equal(modTime) && idx.size == size {
+		return idx, nil
+	}
+
+	entries, err := readDB(st, dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &index{
+		modTime: modTime,
+		size:    size,
+		entries: entries,
+		sorted:  make([]*dbEntry, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		pkg := entry.pkg
+		for _, list := range []*[]string{
+			&pkg.Groups, &pkg.License, &pkg.Replaces, &pkg.Conflicts,
+			&pkg.Provides, &pkg.Depends, &pkg.OptDepends,
+			&pkg.MakeDepends, &pkg.CheckDepends, &pkg.XData,
+		} {
+			if *list == nil {
+				*list = []string{}
+			}
+		}
+		idx.sorted = append(idx.sorted, entry)
+	}
+
+	sort.Slice(idx.sorted, func(i, j int) bool {
+		return idx.sorted[i].dir() < idx.sorted[j].dir()
+	})
+
+	cached.idx = idx
+
+	return idx, nil
+}
+
+// invalidateIndex drops the cached index of a files db.
+func invalidateIndex(dbPath string) {
+	indexes.Lock()
+	defer indexes.Unlock()
+	delete(indexes.m, dbPath)
+}
+
+// Package returns a copy of the package of the entry. If files is true the
+// file list of the package is included.
+func (e *dbEntry) Package(files bool) *model.Package {
+	pkg := *e.pkg
+	pkg.Files = []string{}
+
+	if files && len(e.files) > 0 {
+		pkg.Files = strings.Split(string(e.files), "\n")
+		pkg.Files = pkg.Files[1:]
+		if len(pkg.Files) > 0 && pkg.Files[len(pkg.Files)-1] == "" {
+			pkg.Files = pkg.Files[:len(pkg.Files)-1]
+		}
+	}
+
+	return &pkg
+}
@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that the index is cached and rebuilt when the db changes.
+func TestIndex(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "index"}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	err = r.InitEmptyDBs()
+	assert.NoError(t, err, "should not fail")
+
+	idx, err := r.index("x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, idx.entries, 0, "should have length 0")
+
+	cached, err := NewRepo(r.Repo, repoStorage).index("x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.True(t, idx == cached, "should be the same index")
+
+	pkg := writeTestPkg(t, r.Path(), "foo", "1.0-1", "x86_64")
+	err = r.Add([]string{pkg})
+	assert.NoError(t, err, "should not fail")
+
+	idx, err = r.index("x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, idx.entries, 1, "should have length 1")
+
+	// packages returned are copies of the indexed packages
+	pkgs, err := r.Packages("x86_64", true)
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, pkgs, 1, "should have length 1")
+	assert.Equal(t, []string{"usr/", "usr/share/foo/README"}, pkgs[0].Files, "should be equal")
+	pkgs[0].Name = "bar"
+
+	p, err := r.Package("foo", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.NotNil(t, p, "should not be nil")
+	assert.Len(t, p.Files, 0, "should have length 0")
+}
+
+// blockingStorage blocks Stat of a path until unblock is closed.
+type blockingStorage struct {
+	LocalStorage
+	path    string
+	blocked chan struct{}
+	unblock chan struct{}
+}
+
+func (s *blockingStorage) Stat(path string) (*FileInfo, error) {
+	if path == s.path {
+		close(s.blocked)
+		<-s.unblock
+	}
+	return s.LocalStorage.Stat(path)
+}
+
+// Test that loading the index of a db doesn't wait for other dbs.
+func TestIndexConcurrent(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "index-concurrent", Archs: []string{"x86_64", "aarch64"}}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	err = r.InitEmptyDBs()
+	assert.NoError(t, err, "should not fail")
+
+	st := &blockingStorage{
+		path:    r.FilesDB("x86_64"),
+		blocked: make(chan struct{}),
+		unblock: make(chan struct{}),
+	}
+	blocked := NewRepoStorage(r.Repo, repoStorage, st)
+
+	errc := make(chan error)
+	go func() {
+		_, err := blocked.index("x86_64")
+		errc <- err
+	}()
+	<-st.blocked
+
+	done := make(chan error)
+	go func() {
+		_, err := blocked.index("aarch64")
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		assert.NoError(t, err, "should not fail")
+	case <-time.After(5 * time.Second):
+		t.Error("should not block")
+	}
+
+	close(st.unblock)
+	assert.NoError(t, <-errc, "should not fail")
+}
@@ -0,0 +1,169 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/mikkeloscar/maze/model"
+)
+
+// SignatureError describes a package which failed signature verification.
+type SignatureError struct {
+	File  string
+	KeyID string
+	Err   error
+}
+
+func (e *SignatureError) Error() string {
+	if e.KeyID != "" {
+		return fmt.Sprintf("%s: signature by key %s: %s", e.File, e.KeyID, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Err)
+}
+
+// ParseKeys parses armored public keys into a list of trusted keys.
+func ParseKeys(armored string) ([]*model.Key, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*model.Key, 0, len(entities))
+
+	for _, entity := range entities {
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		err = entity.Serialize(w)
+		if err != nil {
+			return nil, err
+		}
+
+		err = w.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var identity string
+		if id := entity.PrimaryIdentity(); id != nil {
+			identity = id.Name
+		}
+
+		keys = append(keys, &model.Key{
+			KeyID:       entity.PrimaryKey.KeyIdString(),
+			Fingerprint: fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint),
+			Identity:    identity,
+			Armored:     buf.String(),
+		})
+	}
+
+	return keys, nil
+}
+
+// keyring builds a keyring from a list of trusted keys.
+func keyring(keys []*model.Key) (openpgp.EntityList, error) {
+	var el openpgp.EntityList
+
+	for _, key := range keys {
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key.Armored))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted key %s: %s", key.KeyID, err)
+		}
+		el = append(el, entities...)
+	}
+
+	return el, nil
+}
+
+// sigKeyID returns the ID of the key which made the signature, if it can be
+// determined.
+func sigKeyID(sig io.Reader) string {
+	p, err := packet.Read(sig)
+	if err != nil {
+		return ""
+	}
+
+	if s, ok := p.(*packet.Signature); ok && s.IssuerKeyId != nil {
+		return fmt.Sprintf("%016X", *s.IssuerKeyId)
+	}
+
+	return ""
+}
+
+// verifyPkgSignature checks that the detached signature of a package file
+// was made by one of the keys in the keyring.
+func verifyPkgSignature(el openpgp.EntityList, pkgPath string) error {
+	file := path.Base(pkgPath)
+
+	f, err := os.Open(pkgPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sig, err := os.Open(pkgPath + ".sig")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &SignatureError{File: file, Err: fmt.Errorf("missing signature")}
+		}
+		return err
+	}
+	defer sig.Close()
+
+	_, err = openpgp.CheckDetachedSignature(el, f, sig, nil)
+	if err != nil {
+		_, serr := sig.Seek(0, io.SeekStart)
+		if serr != nil {
+			return serr
+		}
+
+		return &SignatureError{File: file, KeyID: sigKeyID(sig), Err: err}
+	}
+
+	return nil
+}
+
+// VerifyPkgSignatures checks that every package in the list has a detached
+// signature made by one of the trusted keys. Signature files in the list are
+// skipped. A *SignatureError is returned for the first package which fails
+// verification.
+func VerifyPkgSignatures(pkgPaths []string, keys []*model.Key) error {
+	el, err := keyring(keys)
+	if err != nil {
+		return err
+	}
+
+	for _, pkg := range pkgPaths {
+		if strings.HasSuffix(pkg, ".sig") {
+			continue
+		}
+
+		err := verifyPkgSignature(el, pkg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckPkgSignatures verifies the signatures of package files against the
+// trusted keys if the repo requires signed packages, i.e. RequireSignatures
+// is set or the repo has trusted keys. Unsigned packages are rejected then.
+func (r *Repo) CheckPkgSignatures(pkgPaths []string, keys []*model.Key) error {
+	if !r.RequireSignatures && len(keys) == 0 {
+		return nil
+	}
+
+	return VerifyPkgSignatures(pkgPaths, keys)
+}
@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test verifying package signatures against trusted keys.
+func TestVerifyPkgSignatures(t *testing.T) {
+	trustedKey, err := NewSigningKey("owner", "trusted")
+	assert.NoError(t, err, "should not fail")
+	trusted := NewRepo(&model.Repo{Name: "trusted", SigningKey: trustedKey}, repoStorage)
+
+	otherKey, err := NewSigningKey("owner", "other")
+	assert.NoError(t, err, "should not fail")
+	other := NewRepo(&model.Repo{Name: "other", SigningKey: otherKey}, repoStorage)
+
+	pubKey, err := trusted.PublicKey()
+	assert.NoError(t, err, "should not fail")
+
+	keys, err := ParseKeys(string(pubKey))
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, keys, 1, "should have length 1")
+	assert.Equal(t, "owner/trusted (maze repository signing key)", keys[0].Identity, "should be equal")
+	assert.Len(t, keys[0].KeyID, 16, "should have length 16")
+
+	err = repo2.InitDir()
+	assert.NoError(t, err, "should not fail")
+
+	pkgPath := "test_files/repo2/x86_64/ca-certificates-20150402-1-any.pkg.tar.xz"
+
+	cmd := exec.Command(
+		"cp",
+		"test_files/repo1/x86_64/ca-certificates-20150402-1-any.pkg.tar.xz",
+		pkgPath)
+	err = cmd.Run()
+	assert.NoError(t, err, "should not fail")
+
+	// missing signature
+	err = VerifyPkgSignatures([]string{pkgPath}, keys)
+	assert.IsType(t, &SignatureError{}, err, "should be a signature error")
+
+	// signature by untrusted key
+	err = other.signPkgs([]string{pkgPath})
+	assert.NoError(t, err, "should not fail")
+
+	sigErr := VerifyPkgSignatures([]string{pkgPath, pkgPath + ".sig"}, keys)
+	assert.IsType(t, &SignatureError{}, sigErr, "should be a signature error")
+	otherEntity, err := other.signingEntity()
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, otherEntity.PrimaryKey.KeyIdString(), sigErr.(*SignatureError).KeyID, "should be equal")
+
+	// signature by trusted key
+	err = os.Remove(pkgPath + ".sig")
+	assert.NoError(t, err, "should not fail")
+
+	err = trusted.signPkgs([]string{pkgPath})
+	assert.NoError(t, err, "should not fail")
+
+	err = VerifyPkgSignatures([]string{pkgPath, pkgPath + ".sig"}, keys)
+	assert.NoError(t, err, "should not fail")
+
+	// clean
+	err = repo2.ClearPath()
+	assert.NoError(t, err, "should not fail")
+}
+
+// Test that unsigned packages are rejected by repos requiring signatures
+// even without trusted keys.
+func TestCheckPkgSignatures(t *testing.T) {
+	pkgPath := writeTestPkg(t, t.TempDir(), "foo", "1.0-1", "any")
+
+	r := NewRepo(&model.Repo{Name: "requiresigs"}, repoStorage)
+
+	err := r.CheckPkgSignatures([]string{pkgPath}, nil)
+	assert.NoError(t, err, "should not fail")
+
+	r.RequireSignatures = true
+
+	err = r.CheckPkgSignatures([]string{pkgPath}, nil)
+	assert.IsType(t, &SignatureError{}, err, "should be a signature error")
+	assert.EqualError(t, err.(*SignatureError).Err, "missing signature", "should be equal")
+
+	// a signature can't be trusted without trusted keys.
+	key, err := NewSigningKey("owner", "requiresigs")
+	assert.NoError(t, err, "should not fail")
+	signer := NewRepo(&model.Repo{Name: "requiresigs", SigningKey: key}, repoStorage)
+
+	err = signer.signPkgs([]string{pkgPath})
+	assert.NoError(t, err, "should not fail")
+
+	err = r.CheckPkgSignatures([]string{pkgPath}, nil)
+	assert.IsType(t, &SignatureError{}, err, "should be a signature error")
+}
@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/gofrs/flock"
+)
+
+// repoLock serializes changes to a repo. Within the process a RWMutex is
+// shared by all Repo instances of the repo, and across processes, e.g.
+// several maze instances sharing the repo storage on an NFS volume, an
+// advisory file lock is held as well. Readers of the dbs don't need the
+// lock as the dbs are always replaced atomically.
+type repoLock struct {
+	mu   sync.RWMutex
+	file *flock.Flock
+
+	// readers counts the holders of the read lock sharing the file
+	// lock.
+	readersMu sync.Mutex
+	readers   int
+}
+
+// repoLocks is the registry of repo locks keyed by lock file.
+var repoLocks = struct {
+	sync.Mutex
+	m map[string]*repoLock
+}{m: make(map[string]*repoLock)}
+
+// lockFile returns the path of the file locked when changing the repo. It's
+// kept in the lock storage, next to the upload dir of the repo by default
+// rather than in it, such that it survives the repo being cleared.
+func (r *Repo) lockFile() string {
+	return path.Join(r.lockPath, r.Owner, "."+r.Name+".lock")
+}
+
+// repoLock returns the lock of the repo.
+func (r *Repo) repoLock() *repoLock {
+	file := r.lockFile()
+
+	repoLocks.Lock()
+	defer repoLocks.Unlock()
+
+	l, ok := repoLocks.m[file]
+	if !ok {
+		l = &repoLock{file: flock.New(file)}
+		repoLocks.m[file] = l
+	}
+
+	return l
+}
+
+// removeLockFile removes the lock file of the repo, which must be write
+// locked by the caller, and drops the lock from the registry. A process
+// waiting for the lock gets a lock on the removed file, so it's only done
+// once the repo is deleted or renamed.
+func (r *Repo) removeLockFile() error {
+	file := r.lockFile()
+
+	repoLocks.Lock()
+	delete(repoLocks.m, file)
+	repoLocks.Unlock()
+
+	err := os.Remove(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// lock takes the write lock of the repo and returns a function releasing
+// it.
+func (r *Repo) lock() (func(), error) {
+	l := r.repoLock()
+
+	l.mu.Lock()
+
+	err := l.lockFile(l.file.Lock)
+	if err != nil {
+		l.mu.Unlock()
+		return nil, err
+	}
+
+	return func() {
+		l.file.Unlock()
+		l.mu.Unlock()
+	}, nil
+}
+
+// rlock takes the read lock of the repo and returns a function releasing
+// it.
+func (r *Repo) rlock() (func(), error) {
+	l := r.repoLock()
+
+	l.mu.RLock()
+
+	l.readersMu.Lock()
+	defer l.readersMu.Unlock()
+
+	if l.readers == 0 {
+		err := l.lockFile(l.file.RLock)
+		if err != nil {
+			l.mu.RUnlock()
+			return nil, err
+		}
+	}
+	l.readers++
+
+	return func() {
+		l.readersMu.Lock()
+		l.readers--
+		if l.readers == 0 {
+			l.file.Unlock()
+		}
+		l.readersMu.Unlock()
+
+		l.mu.RUnlock()
+	}, nil
+}
+
+// lockFile locks the lock file with lock, creating its directory if
+// needed.
+func (l *repoLock) lockFile(lock func() error) error {
+	err := os.MkdirAll(path.Dir(l.file.Path()), 0755)
+	if err != nil {
+		return err
+	}
+
+	return lock()
+}
+
+// lockRepos takes the write locks of repos in a fixed order, such that
+// concurrent operations on the same repos can't deadlock. The returned
+// function releases the locks.
+func lockRepos(repos ...*Repo) (func(), error) {
+	sorted := append([]*Repo(nil), repos...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].lockFile() < sorted[j].lockFile()
+	})
+
+	var unlocks []func()
+
+	unlock := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+
+	for i, r := range sorted {
+		if i > 0 && sorted[i-1].lockFile() == r.lockFile() {
+			continue
+		}
+
+		u, err := r.lock()
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		unlocks = append(unlocks, u)
+	}
+
+	return unlock, nil
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that repo instances and other processes share the repo lock.
+func TestRepoLock(t *testing.T) {
+	a := NewRepo(&model.Repo{Owner: "owner", Name: "lock"}, repoStorage)
+	b := NewRepo(&model.Repo{Owner: "owner", Name: "lock"}, repoStorage)
+	defer os.RemoveAll(a.UploadPath())
+	defer os.Remove(a.lockFile())
+
+	// a different open file behaves like another process.
+	other := flock.New(a.lockFile())
+
+	unlock, err := a.lock()
+	assert.NoError(t, err, "should not fail")
+
+	ok, err := other.TryRLock()
+	assert.NoError(t, err, "should not fail")
+	assert.False(t, ok, "should be locked")
+
+	locked := make(chan struct{})
+	go func() {
+		unlock, err := b.lock()
+		assert.NoError(t, err, "should not fail")
+		close(locked)
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("lock should be held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+
+	// readers share the lock.
+	unlockA, err := a.rlock()
+	assert.NoError(t, err, "should not fail")
+	unlockB, err := b.rlock()
+	assert.NoError(t, err, "should not fail")
+
+	ok, err = other.TryRLock()
+	assert.NoError(t, err, "should not fail")
+	assert.True(t, ok, "should not be locked")
+	other.Unlock()
+
+	unlockA()
+
+	ok, err = other.TryLock()
+	assert.NoError(t, err, "should not fail")
+	assert.False(t, ok, "should be locked")
+
+	unlockB()
+
+	ok, err = other.TryLock()
+	assert.NoError(t, err, "should not fail")
+	assert.True(t, ok, "should not be locked")
+	other.Unlock()
+}
+
+// Test that the lock file is removed with the repo.
+func TestRepoLockClear(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "lockclear"}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+
+	_, err = os.Stat(r.lockFile())
+	assert.NoError(t, err, "should not fail")
+
+	err = r.ClearPath()
+	assert.NoError(t, err, "should not fail")
+
+	_, err = os.Stat(r.lockFile())
+	assert.True(t, os.IsNotExist(err), "should not exist")
+}
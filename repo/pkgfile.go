@@ -0,0 +1,321 @@
+package repo
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/ulikunitz/xz"
+)
+
+// maxSigSize is the maximum size of a detached package signature. Same limit
+// as used by repo-add.
+const maxSigSize = 16384
+
+// pkgInfo describes the content of a .PKGINFO file found in a package
+// archive.
+type pkgInfo struct {
+	name         string
+	base         string
+	version      string
+	desc         string
+	url          string
+	buildDate    string
+	packager     string
+	size         string
+	arch         string
+	groups       []string
+	licenses     []string
+	replaces     []string
+	conflicts    []string
+	provides     []string
+	depends      []string
+	optDepends   []string
+	makeDepends  []string
+	checkDepends []string
+	xdata        []string
+}
+
+// parsePkgInfo parses the content of a .PKGINFO file.
+func parsePkgInfo(rdr io.Reader) (*pkgInfo, error) {
+	info := &pkgInfo{}
+
+	scanner := bufio.NewScanner(rdr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		split := strings.SplitN(line, " = ", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid .PKGINFO line: %s", line)
+		}
+
+		key, value := split[0], split[1]
+
+		switch key {
+		case "pkgname":
+			info.name = value
+		case "pkgbase":
+			info.base = value
+		case "pkgver":
+			info.version = value
+		case "pkgdesc":
+			info.desc = value
+		case "url":
+			info.url = value
+		case "builddate":
+			info.buildDate = value
+		case "packager":
+			info.packager = value
+		case "size":
+			info.size = value
+		case "arch":
+			info.arch = value
+		case "group":
+			info.groups = append(info.groups, value)
+		case "license":
+			info.licenses = append(info.licenses, value)
+		case "replaces":
+			info.replaces = append(info.replaces, value)
+		case "conflict":
+			info.conflicts = append(info.conflicts, value)
+		case "provides":
+			info.provides = append(info.provides, value)
+		case "depend":
+			info.depends = append(info.depends, value)
+		case "optdepend":
+			info.optDepends = append(info.optDepends, value)
+		case "makedepend":
+			info.makeDepends = append(info.makeDepends, value)
+		case "checkdepend":
+			info.checkDepends = append(info.checkDepends, value)
+		case "xdata":
+			info.xdata = append(info.xdata, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if info.name == "" || info.version == "" || info.arch == "" {
+		return nil, fmt.Errorf("invalid .PKGINFO: missing pkgname, pkgver or arch")
+	}
+
+	return info, nil
+}
+
+// Package returns the package metadata described by the .PKGINFO.
+func (i *pkgInfo) Package() *model.Package {
+	pkg := &model.Package{
+		Name:         i.name,
+		Base:         i.base,
+		Version:      i.version,
+		Desc:         i.desc,
+		Groups:       i.groups,
+		ISize:        i.size,
+		URL:          i.url,
+		License:      i.licenses,
+		Arch:         i.arch,
+		Packager:     i.packager,
+		Replaces:     i.replaces,
+		Conflicts:    i.conflicts,
+		Provides:     i.provides,
+		Depends:      i.depends,
+		OptDepends:   i.optDepends,
+		MakeDepends:  i.makeDepends,
+		CheckDepends: i.checkDepends,
+		XData:        i.xdata,
+	}
+
+	if buildDate, err := strconv.ParseInt(i.buildDate, 10, 64); err == nil {
+		pkg.BuildDate = time.Unix(buildDate, 0)
+	}
+
+	return pkg
+}
+
+// ValidPkgFilename returns true if file is a valid package or package
+// signature filename.
+func ValidPkgFilename(file string) bool {
+	return pkgPatt.FindString(file) == file
+}
+
+// ValidatePkgFile reads the .PKGINFO of a local package file and checks
+// that the package name, version and arch match the file name. The package
+// metadata is returned.
+func ValidatePkgFile(pkgPath string) (*model.Package, error) {
+	file := path.Base(pkgPath)
+
+	if !ValidPkgFilename(file) || strings.HasSuffix(file, ".sig") {
+		return nil, fmt.Errorf("invalid package filename: %s", file)
+	}
+
+	name, version, arch, err := splitFileNameVersion(file)
+	if err != nil {
+		return nil, err
+	}
+
+	info, _, err := readPkgFile(localFS, pkgPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid package %s: %s", file, err)
+	}
+
+	switch {
+	case info.name != name:
+		return nil, fmt.Errorf("package name '%s' doesn't match filename %s", info.name, file)
+	case info.version != version:
+		return nil, fmt.Errorf("package version '%s' doesn't match filename %s", info.version, file)
+	case info.arch != arch:
+		return nil, fmt.Errorf("package arch '%s' doesn't match filename %s", info.arch, file)
+	}
+
+	return info.Package(), nil
+}
+
+// decompressor returns a reader decompressing rdr based on the extension of
+// the package filename.
+func decompressor(file string, rdr io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(file, ".xz"):
+		xzr, err := xz.NewReader(rdr)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xzr), nil
+	case strings.HasSuffix(file, ".zst"):
+		zr, err := zstd.NewReader(rdr)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case strings.HasSuffix(file, ".gz"):
+		return gzip.NewReader(rdr)
+	default:
+		return nil, fmt.Errorf("unsupported package compression: %s", file)
+	}
+}
+
+// readPkgFile reads the .PKGINFO and the file list of a package archive.
+// The file list is sorted and excludes the package metadata files.
+func readPkgFile(st Storage, file string) (*pkgInfo, []string, error) {
+	f, err := st.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	dr, err := decompressor(file, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer dr.Close()
+
+	var info *pkgInfo
+	var files []string
+
+	tarR := tar.NewReader(dr)
+	for {
+		header, err := tarR.Next()
+		if err != nil {
+			if err != io.EOF {
+				return nil, nil, err
+			}
+
+			break
+		}
+
+		name := strings.TrimPrefix(header.Name, "./")
+		if strings.HasPrefix(name, ".") {
+			if name == ".PKGINFO" {
+				info, err = parsePkgInfo(tarR)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			continue
+		}
+
+		if header.Typeflag == tar.TypeDir && !strings.HasSuffix(name, "/") {
+			name += "/"
+		}
+
+		files = append(files, name)
+	}
+
+	if info == nil {
+		return nil, nil, fmt.Errorf("no .PKGINFO found in package %s", path.Base(file))
+	}
+
+	sort.Strings(files)
+
+	return info, files, nil
+}
+
+// pkgChecksums returns the size, md5 and sha256 sums of a package file.
+func pkgChecksums(st Storage, file string) (int64, string, string, error) {
+	f, err := st.Open(file)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer f.Close()
+
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), f)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	return size,
+		hex.EncodeToString(md5Hash.Sum(nil)),
+		hex.EncodeToString(sha256Hash.Sum(nil)),
+		nil
+}
+
+// readPkgSig reads the detached signature of a package file, if any, and
+// returns it base64 encoded.
+func readPkgSig(st Storage, file string) (string, error) {
+	f, err := st.Stat(file + ".sig")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if f.Size > maxSigSize {
+		return "", fmt.Errorf("signature %s.sig is too large", path.Base(file))
+	}
+
+	rdr, err := st.Open(file + ".sig")
+	if err != nil {
+		return "", err
+	}
+	defer rdr.Close()
+
+	sig, err := ioutil.ReadAll(rdr)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
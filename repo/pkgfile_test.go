@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+// writeTestPkg writes a minimal package file to dir and returns the path to
+// it. Extra .PKGINFO lines e.g. "depend = foo" can be passed in pkginfo.
+func writeTestPkg(t *testing.T, dir, name, version, arch string, pkginfo ...string) string {
+	pkgPath := path.Join(dir, fmt.Sprintf("%s-%s-%s.pkg.tar.xz", name, version, arch))
+
+	f, err := os.Create(pkgPath)
+	assert.NoError(t, err, "should not fail")
+	defer f.Close()
+
+	xzw, err := xz.NewWriter(f)
+	assert.NoError(t, err, "should not fail")
+
+	tarW := tar.NewWriter(xzw)
+
+	lines := append([]string{
+		"pkgname = " + name,
+		"pkgbase = " + name,
+		"pkgver = " + version,
+		"pkgdesc = test package " + name,
+		"builddate = 1428007012",
+		"packager = maze <maze@example.org>",
+		"size = 1024",
+		"arch = " + arch,
+	}, pkginfo...)
+	content := strings.Join(lines, "\n") + "\n"
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{".PKGINFO", content},
+		{"usr/share/" + name + "/README", name},
+	}
+
+	err = tarW.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "usr/", Mode: 0755})
+	assert.NoError(t, err, "should not fail")
+
+	for _, file := range files {
+		err = tarW.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.name,
+			Mode:     0644,
+			Size:     int64(len(file.content)),
+		})
+		assert.NoError(t, err, "should not fail")
+
+		_, err = tarW.Write([]byte(file.content))
+		assert.NoError(t, err, "should not fail")
+	}
+
+	assert.NoError(t, tarW.Close(), "should not fail")
+	assert.NoError(t, xzw.Close(), "should not fail")
+
+	return pkgPath
+}
+
+// Test reading .PKGINFO and the file list of a package.
+func TestReadPkgFile(t *testing.T) {
+	info, files, err := readPkgFile(localFS, "test_files/repo1/x86_64/ca-certificates-20150402-1-any.pkg.tar.xz")
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, "ca-certificates", info.name, "should be equal")
+	assert.Equal(t, "20150402-1", info.version, "should be equal")
+	assert.Equal(t, "any", info.arch, "should be equal")
+	assert.Equal(t, []string{"ca-certificates-mozilla", "ca-certificates-cacert"}, info.depends, "should be equal")
+	assert.Len(t, files, 0, "should have length 0")
+
+	dir, err := os.MkdirTemp("", "maze")
+	assert.NoError(t, err, "should not fail")
+	defer os.RemoveAll(dir)
+
+	pkgPath := writeTestPkg(t, dir, "foo", "1.0-1", "x86_64", "provides = bar=1.0")
+	info, files, err = readPkgFile(localFS, pkgPath)
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, "foo", info.name, "should be equal")
+	assert.Equal(t, []string{"bar=1.0"}, info.provides, "should be equal")
+	assert.Equal(t, []string{"usr/", "usr/share/foo/README"}, files, "should be equal")
+
+	_, err = parsePkgInfo(strings.NewReader("pkgname = foo\n"))
+	assert.Error(t, err, "should fail")
+}
+
+// Test validating package files against their .PKGINFO.
+func TestValidatePkgFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "maze")
+	assert.NoError(t, err, "should not fail")
+	defer os.RemoveAll(dir)
+
+	pkgPath := writeTestPkg(t, dir, "foo", "1:1.0-1", "x86_64", "group = devel")
+	pkg, err := ValidatePkgFile(pkgPath)
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, "foo", pkg.Name, "should be equal")
+	assert.Equal(t, "1:1.0-1", pkg.Version, "should be equal")
+	assert.Equal(t, "x86_64", pkg.Arch, "should be equal")
+	assert.Equal(t, []string{"devel"}, pkg.Groups, "should be equal")
+	assert.Equal(t, int64(1428007012), pkg.BuildDate.Unix(), "should be equal")
+
+	// metadata not matching the filename.
+	for _, name := range []string{
+		"bar-1:1.0-1-x86_64.pkg.tar.xz",
+		"foo-1.0-1-x86_64.pkg.tar.xz",
+		"foo-1:1.0-1-aarch64.pkg.tar.xz",
+	} {
+		renamed := path.Join(dir, name)
+		err = os.Link(pkgPath, renamed)
+		assert.NoError(t, err, "should not fail")
+
+		_, err = ValidatePkgFile(renamed)
+		assert.Error(t, err, "should fail for %s", name)
+	}
+
+	// invalid filenames.
+	for _, name := range []string{"foo.tar.xz", "foo-1:1.0-1-x86_64.pkg.tar.xz.sig", "x/../foo-1:1.0-1-x86_64.pkg.tar.xz.part"} {
+		_, err = ValidatePkgFile(path.Join(dir, name))
+		assert.Error(t, err, "should fail for %s", name)
+	}
+
+	// corrupt archive.
+	data, err := os.ReadFile(pkgPath)
+	assert.NoError(t, err, "should not fail")
+	err = os.WriteFile(pkgPath, data[:len(data)/2], 0644)
+	assert.NoError(t, err, "should not fail")
+
+	_, err = ValidatePkgFile(pkgPath)
+	assert.Error(t, err, "should fail")
+}
@@ -0,0 +1,180 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/mikkeloscar/maze/common/util"
+	"github.com/mikkeloscar/maze/model"
+)
+
+// signedByRepo returns true if the detached signature of a package file was
+// made by the signing key of the repo.
+func (r *Repo) signedByRepo(pkgPath string) bool {
+	entity, err := r.signingEntity()
+	if err != nil || entity == nil {
+		return false
+	}
+
+	return verifyPkgSignature(openpgp.EntityList{entity}, pkgPath) == nil
+}
+
+// Promote adds the named package versions (name -> version) of the repo to
+// the target repo. The package files of the repo are reused rather than
+// copied when the storage allows it. Signatures made by the signing key of the repo are
+// not carried over, such that the target repo signs the packages with its
+// own key. The packages must pass the signature check of the target repo
+// against its trusted keys and, if the target repo checks dependencies, the
+// dependency check against its linked repos. If move is true the packages are
+// removed from the repo once they have been added to the target repo.
+func (r *Repo) Promote(target *Repo, pkgs map[string]string, move bool, keys []*model.Key, linked []*Repo) error {
+	repos := []*Repo{target}
+	if move {
+		repos = append(repos, r)
+	}
+
+	// the versions are resolved with the locks held, such that a move
+	// removes exactly the promoted versions from the repo.
+	unlock, err := lockRepos(repos...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var files []string
+	seen := make(map[string]struct{})
+	archPkgs := make(map[string][]string)
+
+	for name, version := range pkgs {
+		found := false
+
+		for _, arch := range r.Archs {
+			pkg, err := r.Package(name, arch, false)
+			if err != nil {
+				removeFiles(files)
+				return err
+			}
+
+			if pkg == nil || pkg.Version != version {
+				continue
+			}
+
+			found = true
+			archPkgs[arch] = append(archPkgs[arch], name)
+
+			if pkg.Arch != "any" && !util.StrContains(pkg.Arch, target.Archs) {
+				removeFiles(files)
+				return fmt.Errorf("target repo doesn't have arch '%s' of package %s", pkg.Arch, name)
+			}
+
+			// 'any' packages are the same file in all archs.
+			if _, ok := seen[pkg.FileName]; ok {
+				continue
+			}
+			seen[pkg.FileName] = struct{}{}
+
+			src := path.Join(r.PathDeep(arch), pkg.FileName)
+			dst := path.Join(target.UploadPath(), pkg.FileName)
+
+			err = r.getPkgFile(src, dst)
+			if err != nil {
+				removeFiles(files)
+				return err
+			}
+
+			if r.signedByRepo(dst) {
+				err := os.Remove(dst + ".sig")
+				if err != nil && !os.IsNotExist(err) {
+					removeFiles(append(files, dst))
+					return err
+				}
+			}
+
+			files = append(files, dst)
+		}
+
+		if !found {
+			removeFiles(files)
+			return fmt.Errorf("%w: %s %s", ErrVersionNotFound, name, version)
+		}
+	}
+
+	err = target.CheckPkgSignatures(files, keys)
+	if err != nil {
+		removeFiles(files)
+		return err
+	}
+
+	if target.CheckDeps {
+		err = target.VerifyDeps(files, linked)
+		if err != nil {
+			removeFiles(files)
+			return err
+		}
+	}
+
+	tt := target.begin()
+
+	err = tt.addPkgs(files)
+	if err != nil {
+		tt.abort()
+		removeFiles(files)
+		return err
+	}
+
+	// the packages are removed from the repo in the same commit as they
+	// are added to the target repo.
+	var st *txn
+	if move {
+		st = r.begin()
+
+		for arch, names := range archPkgs {
+			_, err := st.remove(arch, names)
+			if err != nil {
+				tt.abort()
+				st.abort()
+				removeFiles(files)
+				return err
+			}
+		}
+	}
+
+	err = commit(tt, st)
+	if err != nil {
+		removeFiles(files)
+		return err
+	}
+
+	return nil
+}
+
+// getPkgFile retrieves a package file and its signature, if any, from the
+// repo storage to the local path dst.
+func (r *Repo) getPkgFile(src, dst string) error {
+	err := os.MkdirAll(path.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, ext := range []string{"", ".sig"} {
+		err := r.storage.GetFile(src+ext, dst+ext)
+		if err != nil {
+			if ext != "" && os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeFiles removes local package files and their signatures.
+func removeFiles(files []string) {
+	for _, file := range files {
+		os.Remove(file)
+		os.Remove(file + ".sig")
+	}
+}
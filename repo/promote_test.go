@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test promoting packages between repos.
+func TestPromote(t *testing.T) {
+	stagingKey, err := NewSigningKey("owner", "staging")
+	assert.NoError(t, err, "should not fail")
+	staging := NewRepo(&model.Repo{Name: "staging", SigningKey: stagingKey}, repoStorage)
+
+	stableKey, err := NewSigningKey("owner", "stable")
+	assert.NoError(t, err, "should not fail")
+	stable := NewRepo(&model.Repo{Name: "stable", SigningKey: stableKey}, repoStorage)
+
+	for _, r := range []*Repo{staging, stable} {
+		err = r.InitDir()
+		assert.NoError(t, err, "should not fail")
+		defer r.ClearPath()
+	}
+
+	foo := writeTestPkg(t, staging.Path(), "foo", "1.0-1", "x86_64")
+	bar := writeTestPkg(t, staging.Path(), "bar", "2.0-1", "any")
+	err = staging.Add([]string{foo, bar})
+	assert.NoError(t, err, "should not fail")
+
+	// unknown version
+	err = staging.Promote(stable, map[string]string{"foo": "0.9-1"}, false, nil, nil)
+	assert.True(t, errors.Is(err, ErrVersionNotFound), "should be version not found")
+
+	// copy
+	err = staging.Promote(stable, map[string]string{"foo": "1.0-1"}, false, nil, nil)
+	assert.NoError(t, err, "should not fail")
+
+	pkg, err := stable.Package("foo", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.NotNil(t, pkg, "should not be nil")
+
+	pkg, err = staging.Package("foo", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.NotNil(t, pkg, "should not be nil")
+
+	// promoted packages are signed by the target repo.
+	stableEntity, err := stable.signingEntity()
+	assert.NoError(t, err, "should not fail")
+	checkSig(t, openpgp.EntityList{stableEntity}, path.Join(stable.PathDeep("x86_64"), pkg.FileName))
+
+	// move
+	err = staging.Promote(stable, map[string]string{"bar": "2.0-1"}, true, nil, nil)
+	assert.NoError(t, err, "should not fail")
+
+	pkg, err = stable.Package("bar", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.NotNil(t, pkg, "should not be nil")
+	assert.Equal(t, "2.0-1", pkg.Version, "should be equal")
+
+	pkg, err = staging.Package("bar", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.Nil(t, pkg, "should be nil")
+
+	// the source files are left untouched by the promotion.
+	_, err = os.Stat(path.Join(staging.PathDeep("x86_64"), "foo-1.0-1-x86_64.pkg.tar.xz"))
+	assert.NoError(t, err, "should not fail")
+}
+
+// Test that promoted packages must pass the checks of the target repo.
+func TestPromoteChecks(t *testing.T) {
+	staging := NewRepo(&model.Repo{Name: "staging-checks"}, repoStorage)
+	signed := NewRepo(&model.Repo{Name: "signed", RequireSignatures: true}, repoStorage)
+	checked := NewRepo(&model.Repo{Name: "checked", CheckDeps: true}, repoStorage)
+
+	for _, r := range []*Repo{staging, signed, checked} {
+		err := r.InitDir()
+		assert.NoError(t, err, "should not fail")
+		defer r.ClearPath()
+	}
+
+	foo := writeTestPkg(t, staging.Path(), "foo", "1.0-1", "x86_64", "depend = missing")
+	err := staging.Add([]string{foo})
+	assert.NoError(t, err, "should not fail")
+
+	err = staging.Promote(signed, map[string]string{"foo": "1.0-1"}, true, nil, nil)
+	_, ok := err.(*SignatureError)
+	assert.True(t, ok, "should be a signature error")
+
+	err = staging.Promote(checked, map[string]string{"foo": "1.0-1"}, true, nil, nil)
+	_, ok = err.(*DepsError)
+	assert.True(t, ok, "should be a deps error")
+
+	for _, r := range []*Repo{signed, checked} {
+		pkg, err := r.Package("foo", "x86_64", false)
+		assert.NoError(t, err, "should not fail")
+		assert.Nil(t, pkg, "should be nil")
+	}
+
+	pkg, err := staging.Package("foo", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.NotNil(t, pkg, "should not be nil")
+
+	// the fetched package files are removed again.
+	_, err = os.Stat(path.Join(signed.UploadPath(), "foo-1.0-1-x86_64.pkg.tar.xz"))
+	assert.True(t, os.IsNotExist(err), "should not exist")
+}
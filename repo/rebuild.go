@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mikkeloscar/gopkgbuild"
+)
+
+// RebuildResult describes how the db of an arch was rebuilt.
+type RebuildResult struct {
+	Arch string `json:"arch"`
+	// Packages are the package files added to the db.
+	Packages []string `json:"packages"`
+	// Archived are the older package versions moved to the archive.
+	Archived []string `json:"archived"`
+	// Invalid are the files which couldn't be read as packages of the
+	// arch. They are left untouched.
+	Invalid []string `json:"invalid"`
+}
+
+// Rebuild regenerates the dbs of all archs from scratch based on the
+// package files found in the arch dirs. The newest version of each package
+// is added to the db, older versions are moved to the archive. Existing
+// package signatures are kept. The dbs of all archs are published at once.
+func (r *Repo) Rebuild() ([]*RebuildResult, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	results := make([]*RebuildResult, 0, len(r.Archs))
+
+	t := r.begin()
+
+	for _, arch := range r.Archs {
+		result, err := r.rebuildArch(t, arch)
+		if err != nil {
+			t.abort()
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	err = t.commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// rebuildArch replaces the db of an arch in the txn with one generated from
+// the package files of the arch dir.
+func (r *Repo) rebuildArch(t *txn, arch string) (*RebuildResult, error) {
+	result := &RebuildResult{
+		Arch:     arch,
+		Packages: []string{},
+		Archived: []string{},
+		Invalid:  []string{},
+	}
+
+	files, err := r.storage.List(r.PathDeep(arch))
+	if err != nil {
+		return nil, err
+	}
+
+	type pkgFile struct {
+		file    string
+		version *pkgbuild.CompleteVersion
+	}
+
+	byName := make(map[string][]*pkgFile)
+
+	for _, f := range files {
+		if f.IsDir || strings.HasSuffix(f.Name, ".sig") || r.isDBFile(f.Name) {
+			continue
+		}
+
+		name, version, pkgArch, err := splitFileNameVersion(f.Name)
+		if err != nil || (pkgArch != arch && pkgArch != "any") {
+			result.Invalid = append(result.Invalid, f.Name)
+			continue
+		}
+
+		v, err := pkgbuild.NewCompleteVersion(version)
+		if err != nil {
+			result.Invalid = append(result.Invalid, f.Name)
+			continue
+		}
+
+		byName[name] = append(byName[name], &pkgFile{f.Name, v})
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make(map[string]*dbEntry, len(byName))
+	var archive []string
+
+	for _, name := range names {
+		versions := byName[name]
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].version.Newer(versions[j].version)
+		})
+
+		for _, pkg := range versions {
+			if _, ok := entries[name]; ok {
+				archive = append(archive, pkg.file)
+				continue
+			}
+
+			entry, err := newDBEntry(r.storage, path.Join(r.PathDeep(arch), pkg.file))
+			if err != nil || entry.pkg.Name != name {
+				result.Invalid = append(result.Invalid, pkg.file)
+				continue
+			}
+
+			entries[name] = entry
+			result.Packages = append(result.Packages, pkg.file)
+		}
+	}
+
+	t.dbs[arch] = entries
+
+	for _, file := range archive {
+		t.archive(arch, file)
+		result.Archived = append(result.Archived, file)
+	}
+
+	sort.Strings(result.Invalid)
+
+	return result, nil
+}
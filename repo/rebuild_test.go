@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test rebuilding the dbs of a repo from the package files.
+func TestRebuild(t *testing.T) {
+	key, err := NewSigningKey("owner", "rebuild")
+	assert.NoError(t, err, "should not fail")
+
+	r := NewRepo(&model.Repo{Name: "rebuild", SigningKey: key, HistorySize: 2}, repoStorage)
+	err = r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	err = r.Add([]string{writeTestPkg(t, r.Path(), "foo", "1.0-2", "x86_64")})
+	assert.NoError(t, err, "should not fail")
+
+	dir := r.PathDeep("x86_64")
+	writeTestPkg(t, dir, "foo", "1.0-1", "x86_64")
+	writeTestPkg(t, dir, "bar", "1:0.1-1", "any")
+	writeTestPkg(t, dir, "bar", "2.0-1", "any")
+	writeTestPkg(t, dir, "baz", "1.0-1", "aarch64")
+
+	// corrupt the dbs.
+	for _, db := range []string{r.DB("x86_64"), r.FilesDB("x86_64")} {
+		err = os.WriteFile(db, []byte("garbage"), 0644)
+		assert.NoError(t, err, "should not fail")
+	}
+
+	_, err = r.Packages("x86_64", false)
+	assert.Error(t, err, "should fail")
+
+	results, err := r.Rebuild()
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, results, 1, "should be equal")
+
+	result := results[0]
+	assert.Equal(t, "x86_64", result.Arch, "should be equal")
+	assert.Equal(t, []string{"bar-1:0.1-1-any.pkg.tar.xz", "foo-1.0-2-x86_64.pkg.tar.xz"}, result.Packages, "should be equal")
+	assert.Equal(t, []string{"bar-2.0-1-any.pkg.tar.xz", "foo-1.0-1-x86_64.pkg.tar.xz"}, result.Archived, "should be equal")
+	assert.Equal(t, []string{"baz-1.0-1-aarch64.pkg.tar.xz"}, result.Invalid, "should be equal")
+
+	pkgs, err := r.Packages("x86_64", true)
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, pkgs, 2, "should be equal")
+
+	// the signature of the package added before the rebuild is kept.
+	entries, err := readDB(localFS, r.DB("x86_64"))
+	assert.NoError(t, err, "should not fail")
+	assert.Contains(t, string(entries["foo"].desc), "%PGPSIG%", "should be signed")
+	assert.NotContains(t, string(entries["bar"].desc), "%PGPSIG%", "should not be signed")
+
+	_, err = os.Stat(path.Join(r.ArchivePath("x86_64"), "foo-1.0-1-x86_64.pkg.tar.xz"))
+	assert.NoError(t, err, "should not fail")
+
+	_, err = os.Stat(r.DB("x86_64") + ".sig")
+	assert.NoError(t, err, "should not fail")
+}
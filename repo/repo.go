@@ -1,20 +1,16 @@
 package repo
 
 import (
-	"archive/tar"
 	"bufio"
-	"bytes"
-	"compress/gzip"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/mikkeloscar/gopkgbuild"
@@ -22,8 +18,7 @@ import (
 	"github.com/mikkeloscar/maze/model"
 )
 
-var pkgPatt = regexp.MustCompile(`([a-z\d@._+]+[a-z\d@._+-]+)-((\d+:)?([\da-z\._+]+-\d+))-(i686|x86_64|any).pkg.tar.(xz|zst)(.sig)?`)
-var removePatt = regexp.MustCompile(`Removing existing entry '([a-z\d@._+-]+)'`)
+var pkgPatt = regexp.MustCompile(`([a-z\d@._+]+[a-z\d@._+-]+)-((\d+:)?([\da-z\._+]+-\d+))-(i686|x86_64|aarch64|armv7h|any).pkg.tar.(xz|zst|gz)(.sig)?`)
 var pkgNamePatt = regexp.MustCompile(`^[a-z\d@._+][a-z\d@._+-]*$`)
 
 // ValidRepoName returns true if the name is a valid repo name.
@@ -47,51 +42,132 @@ func ValidArchs(archs []string) bool {
 // ValidArch returns true if the arch string is valid.
 func ValidArch(arch string) bool {
 	switch arch {
-	case "x86_64":
-		// fallthrough
-		// case "i686":
+	case "x86_64", "i686", "aarch64", "armv7h":
 		return true
 	default:
 		return false
 	}
 }
 
-// Repo is a wrapper around the arch tools 'repo-add' and 'repo-remove'.
+// DefaultArchs is the list of archs used for repos without any archs
+// defined.
+var DefaultArchs = []string{"x86_64"}
+
+// Repo is a pacman package repository kept in a storage backend.
 type Repo struct {
 	*model.Repo
-	basePath string
-	Archs    []string // TODO: move to model.Repo
-	rwLock   *sync.RWMutex
+	basePath   string
+	uploadPath string
+	lockPath   string
+	storage    Storage
 }
 
+// NewRepo returns a repo stored below basePath in the default storage.
 func NewRepo(r *model.Repo, basePath string) *Repo {
-	return &Repo{r, basePath, []string{"x86_64"}, new(sync.RWMutex)}
+	return NewRepoStorage(r, basePath, DefaultStorage)
+}
+
+// NewRepoStorage returns a repo stored below basePath in a storage.
+func NewRepoStorage(r *model.Repo, basePath string, storage Storage) *Repo {
+	if len(r.Archs) == 0 {
+		r.Archs = append([]string(nil), DefaultArchs...)
+	}
+
+	uploadPath := UploadStorage
+	if uploadPath == "" {
+		uploadPath = basePath
+	}
+
+	lockPath := LockStorage
+	if lockPath == "" {
+		lockPath = uploadPath
+	}
+
+	return &Repo{r, basePath, uploadPath, lockPath, storage}
+}
+
+// Storage returns the storage backend of the repo.
+func (r *Repo) Storage() Storage {
+	return r.storage
 }
 
 func (r *Repo) InitDir() error {
-	r.rwLock.Lock()
-	defer r.rwLock.Unlock()
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	for _, arch := range r.Archs {
-		err := os.MkdirAll(r.PathDeep(arch), 0755)
+		err := r.storage.MkdirAll(r.PathDeep(arch))
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return os.MkdirAll(r.UploadPath(), 0755)
 }
 
 func (r *Repo) ClearPath() error {
-	r.rwLock.Lock()
-	defer r.rwLock.Unlock()
-	return os.RemoveAll(r.Path())
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = r.storage.RemoveAll(r.Path())
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(r.UploadPath())
+	if err != nil {
+		return err
+	}
+
+	return r.removeLockFile()
+}
+
+// SetName changes the name of the repo. The lock file of the old name is
+// removed.
+func (r *Repo) SetName(name string) error {
+	if name == r.Name {
+		return nil
+	}
+
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = r.removeLockFile()
+	if err != nil {
+		return err
+	}
+
+	r.Name = name
+
+	return nil
 }
 
 func (r *Repo) Path() string {
 	return path.Join(r.basePath, r.Owner, r.Name)
 }
 
+// UploadPath returns the local path where uploaded files are kept until
+// they are added to the repo. For repos stored on the local disk this is
+// the same as Path.
+func (r *Repo) UploadPath() string {
+	return path.Join(r.uploadPath, r.Owner, r.Name)
+}
+
+// UploadSessionPath returns the local path where the files of an upload
+// session are kept.
+func (r *Repo) UploadSessionPath(sessionID string) string {
+	return path.Join(r.UploadPath(), ".uploads", sessionID)
+}
+
 func (r *Repo) PathDeep(arch string) string {
 	return path.Join(r.Path(), arch)
 }
@@ -108,37 +184,177 @@ func (r *Repo) FilesDB(arch string) string {
 
 // InitEmptyDBs initialize empty dbs for the repo.
 func (r *Repo) InitEmptyDBs() error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t := r.begin()
+
 	for _, arch := range r.Archs {
-		args := []string{"--nocolor", "-R", r.DB(arch)}
+		t.dbs[arch] = make(map[string]*dbEntry)
+	}
 
-		cmd := exec.Command("repo-add", args...)
-		cmd.Dir = r.PathDeep(arch)
+	return t.commit()
+}
 
-		err := cmd.Run()
+// AddArch adds a new arch to the repo. The arch directory and dbs are
+// created and all 'any' packages of the repo are added to the new arch.
+func (r *Repo) AddArch(arch string) error {
+	if util.StrContains(arch, r.Archs) {
+		return nil
+	}
+
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = r.storage.MkdirAll(r.PathDeep(arch))
+	if err != nil {
+		return err
+	}
+
+	t := r.begin()
+	entries := make(map[string]*dbEntry)
+
+	// 'any' packages are the same in all archs so they can be taken
+	// from any of the existing archs.
+	if len(r.Archs) > 0 {
+		src := r.Archs[0]
+
+		existing, err := readDB(r.storage, r.FilesDB(src))
 		if err != nil {
 			return err
 		}
+
+		for name, entry := range existing {
+			if entry.pkg.Arch != "any" {
+				continue
+			}
+
+			err := t.copyPkgFile(
+				path.Join(r.PathDeep(src), entry.pkg.FileName),
+				path.Join(r.PathDeep(arch), entry.pkg.FileName),
+			)
+			if err != nil {
+				t.abort()
+				return err
+			}
+
+			entries[name] = entry
+		}
 	}
 
+	t.dbs[arch] = entries
+
+	err = t.commit()
+	if err != nil {
+		return err
+	}
+
+	r.Archs = append(r.Archs, arch)
+
 	return nil
 }
 
-// Add adds a list of packages to a repo db, moving the package files to
-// the repo db directory if needed.
+// RemoveArch removes an arch and all its packages from the repo.
+func (r *Repo) RemoveArch(arch string) error {
+	if !util.StrContains(arch, r.Archs) {
+		return nil
+	}
+
+	if len(r.Archs) == 1 {
+		return fmt.Errorf("can't remove the only arch '%s' of the repo", arch)
+	}
+
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t := r.begin()
+
+	err = t.removeDir(r.PathDeep(arch))
+	if err != nil {
+		t.abort()
+		return err
+	}
+
+	err = t.commit()
+	if err != nil {
+		return err
+	}
+
+	archs := make([]string, 0, len(r.Archs)-1)
+	for _, a := range r.Archs {
+		if a != arch {
+			archs = append(archs, a)
+		}
+	}
+	r.Archs = archs
+
+	return nil
+}
+
+// Add adds a list of local package files to a repo db, storing the package
+// files in the repo if needed. Detached signatures (.sig) found next to the
+// packages are stored along with them, unsigned packages are signed if the
+// repo has a signing key. Older versions of the packages are removed from the
+// repo. The dbs of all archs are updated at once; on failure the repo is left
+// unchanged.
 func (r *Repo) Add(pkgPaths []string) error {
 	if len(pkgPaths) == 0 {
 		return nil
 	}
 
-	archPkgs := make(map[string][]string)
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t := r.begin()
+
+	err = t.addPkgs(pkgPaths)
+	if err != nil {
+		t.abort()
+		return err
+	}
+
+	return t.commit()
+}
+
+// addPkgs adds local package files to the txn. The source files are removed
+// once the txn is published unless they are located in an arch dir.
+func (t *txn) addPkgs(pkgPaths []string) error {
+	r := t.r
+	archEntries := make(map[string][]*dbEntry)
 
 	for _, pkg := range pkgPaths {
+		if strings.HasSuffix(pkg, ".sig") {
+			continue
+		}
+
 		pkgPathDir, pkgPathBase := path.Split(pkg)
 		_, _, arch, err := splitFileNameVersion(pkgPathBase)
 		if err != nil {
 			return err
 		}
 
+		err = r.signPkgs([]string{pkg})
+		if err != nil {
+			return err
+		}
+
+		entry, err := newDBEntry(localFS, pkg)
+		if err != nil {
+			return fmt.Errorf("failed to add package %s: %s", pkgPathBase, err)
+		}
+
 		archs := []string{arch}
 
 		if arch == "any" {
@@ -146,88 +362,143 @@ func (r *Repo) Add(pkgPaths []string) error {
 		}
 
 		for _, arch := range archs {
-			if r.PathDeep(arch) != pkgPathDir {
-				// move pkg to repo path.
-				newPath := path.Join(r.PathDeep(arch), pkgPathBase)
-				err := os.Rename(pkg, newPath)
+			if !sameDir(pkgPathDir, r.PathDeep(arch)) {
+				// store pkg in the repo path.
+				err := t.putPkgFile(pkg, path.Join(r.PathDeep(arch), pkgPathBase))
 				if err != nil {
 					return err
 				}
-				archPkgs[arch] = append(archPkgs[arch], newPath)
 			}
+			archEntries[arch] = append(archEntries[arch], entry)
 		}
-	}
 
-	r.rwLock.Lock()
-	defer r.rwLock.Unlock()
-	for arch, pkgs := range archPkgs {
-		args := []string{"--nocolor", "-R", r.DB(arch)}
-		args = append(args, pkgs...)
-
-		var stderr bytes.Buffer
-		cmd := exec.Command("repo-add", args...)
-		cmd.Dir = r.PathDeep(arch)
-		cmd.Stderr = &stderr
+		t.done = append(t.done, func() error {
+			return r.removeSource(pkg, archs)
+		})
+	}
 
-		err := cmd.Run()
+	for arch, entries := range archEntries {
+		err := t.add(arch, entries)
 		if err != nil {
-			return fmt.Errorf("%s: %s", err, stderr.String())
+			return err
 		}
 	}
 
 	return nil
 }
 
-// Remove removes a list of packages from the repo db.
+// Remove removes a list of packages from the repo db. The package files are
+// moved to the archive.
 func (r *Repo) Remove(pkgs []string, arch string) error {
-	args := []string{"--nocolor", r.DB(arch)}
-	args = append(args, pkgs...)
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	cmd := exec.Command("repo-remove", args...)
-	cmd.Dir = r.PathDeep(arch)
+	t := r.begin()
 
-	r.rwLock.Lock()
-	defer r.rwLock.Unlock()
-	out, err := cmd.Output()
-	if err != nil {
+	removed, err := t.remove(arch, pkgs)
+	if err != nil || removed == 0 {
 		return err
 	}
 
-	// remove repo file
-	lines := strings.Split(string(out), "\n")
-	for _, line := range lines {
-		if !strings.HasPrefix(line, "  ->") {
-			continue
-		}
+	return t.commit()
+}
 
-		match := removePatt.FindStringSubmatch(line)
-		if len(match) == 2 {
-			fs, err := ioutil.ReadDir(r.PathDeep(arch))
-			if err != nil {
-				return err
-			}
+// removeFile removes a package file and its signature from an arch dir.
+func (r *Repo) removeFile(arch, file string) error {
+	for _, f := range []string{file, file + ".sig"} {
+		err := r.storage.Remove(path.Join(r.PathDeep(arch), f))
+		if err != nil {
+			return err
+		}
+	}
 
-			for _, f := range fs {
-				if !strings.HasPrefix(f.Name(), match[1]) {
-					continue
-				}
+	return nil
+}
 
-				err := os.Remove(path.Join(r.PathDeep(arch), f.Name()))
+// putPkgFile stores a local package file and its signature, if any, as dst
+// in the repo storage.
+func (r *Repo) putPkgFile(src, dst string) error {
+	for _, ext := range []string{"", ".sig"} {
+		_, err := os.Stat(src + ext)
+		if err != nil {
+			if ext != "" && os.IsNotExist(err) {
+				err = r.storage.Remove(dst + ext)
 				if err != nil {
 					return err
 				}
+				continue
 			}
+			return err
+		}
+
+		err = r.storage.PutFile(dst+ext, src+ext)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyPkgFile copies a package file and its signature, if any, within the
+// repo storage.
+func (r *Repo) copyPkgFile(src, dst string) error {
+	for _, ext := range []string{"", ".sig"} {
+		err := r.storage.Copy(src+ext, dst+ext)
+		if err != nil {
+			if ext != "" && os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeSource removes a package file and its signature after it has been
+// linked into the arch dirs. The file is kept if it's located in one of the
+// arch dirs.
+func (r *Repo) removeSource(pkg string, archs []string) error {
+	for _, arch := range archs {
+		if sameDir(path.Dir(pkg), r.PathDeep(arch)) {
+			return nil
+		}
+	}
+
+	for _, f := range []string{pkg, pkg + ".sig"} {
+		err := os.Remove(f)
+		if err != nil && !os.IsNotExist(err) {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// sameDir returns true if the paths a and b point to the same directory.
+func sameDir(a, b string) bool {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false
+	}
+
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false
+	}
+
+	return absA == absB
+}
+
 // IsNewFilename returns true if pkgfile is a newer version than what's in the
 // repo.
 // If the package is not found in the repo, it will be marked as new.
 func (r *Repo) IsNewFilename(file string) (bool, error) {
-	name, arch, version, err := splitFileNameVersion(file)
+	name, version, arch, err := splitFileNameVersion(file)
 	if err != nil {
 		return false, err
 	}
@@ -243,106 +514,109 @@ func (r *Repo) IsNewFilename(file string) (bool, error) {
 // IsNew returns true if pkg is a newer version than what's in the repo.
 // If the package is not found in the repo, it will be marked as new.
 func (r *Repo) IsNew(name, arch string, version pkgbuild.CompleteVersion) (bool, error) {
-	r.rwLock.RLock()
-	defer r.rwLock.RUnlock()
-
 	archs := []string{arch}
 
 	if arch == "any" {
 		archs = r.Archs
 	}
 
-Loop:
 	for _, arch := range archs {
-
-		f, err := os.Open(r.DB(arch))
+		idx, err := r.index(arch)
 		if err != nil {
-			if os.IsNotExist(err) {
-				break
-			}
 			return false, err
 		}
-		defer f.Close()
 
-		gzf, err := gzip.NewReader(f)
+		entry, ok := idx.entries[name]
+		if !ok {
+			continue
+		}
+
+		parsedVersion, err := pkgbuild.NewCompleteVersion(entry.pkg.Version)
 		if err != nil {
 			return false, err
 		}
 
-		tarR := tar.NewReader(gzf)
-
-		for {
-			header, err := tarR.Next()
-			if err == io.EOF {
-				break
-			}
-
-			if err != nil {
-				return false, err
-			}
-
-			switch header.Typeflag {
-			case tar.TypeDir:
-				n, v := splitNameVersion(header.Name)
-				parsedVersion, err := pkgbuild.NewCompleteVersion(v)
-				if err != nil {
-					return false, err
-				}
-				if n == name {
-					if version.Newer(parsedVersion) {
-						break Loop
-					}
-					return false, nil
-				}
-			case tar.TypeReg:
-				continue
-			}
+		if !version.Newer(parsedVersion) {
+			return false, nil
 		}
 	}
 
 	return true, nil
 }
 
-// Obsolete returns a list of obsolete packages based on the input packages.
-// A package is considered obsolete if it's not in the input list and not a
-// dependency of one of the input packages.
+// Obsolete returns the names of the packages of an arch which are no longer
+// wanted. A package is wanted if its name or base is in the list of packages
+// or if it's a (make) dependency, directly or transitively, of a wanted
+// package. Dependencies are resolved by name and provides within the repo.
 func (r *Repo) Obsolete(pkgs []string, arch string) ([]string, error) {
-	pkgMap, err := r.readPkgMap(arch)
+	packages, err := r.Packages(arch, false)
 	if err != nil {
 		return nil, err
 	}
 
-	return r.obsolete(pkgs, pkgMap), nil
+	return obsolete(pkgs, packages), nil
 }
 
-func (r *Repo) obsolete(pkgs []string, pkgMap map[string]*pkgDep) []string {
-	var obsolete map[string]struct{}
+func obsolete(wanted []string, pkgs []*model.Package) []string {
+	providers := make(map[string][]*model.Package)
+	for _, pkg := range pkgs {
+		providers[pkg.Name] = append(providers[pkg.Name], pkg)
+		for _, provide := range pkg.Provides {
+			name := strings.SplitN(provide, "=", 2)[0]
+			providers[name] = append(providers[name], pkg)
+		}
+	}
+
+	keep := make(map[string]struct{})
+	var queue []*model.Package
 
-	for n := range pkgMap {
-		if !util.StrContains(n, pkgs) {
-			obsolete[n] = struct{}{}
+	for _, pkg := range pkgs {
+		if util.StrContains(pkg.Name, wanted) || util.StrContains(pkg.Base, wanted) {
+			keep[pkg.Name] = struct{}{}
+			queue = append(queue, pkg)
 		}
 	}
 
-	for _, p := range pkgs {
-		if deps, ok := pkgMap[p]; ok {
-			for _, dep := range deps.depends {
-				if _, ok := obsolete[dep]; ok {
-					delete(obsolete, dep)
-					break
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		deps := append(append([]string{}, pkg.Depends...), pkg.MakeDepends...)
+		for _, dep := range deps {
+			name := depName(dep)
+			for _, p := range providers[name] {
+				if _, ok := keep[p.Name]; ok {
+					continue
 				}
+				keep[p.Name] = struct{}{}
+				queue = append(queue, p)
 			}
 		}
 	}
 
-	obsol := make([]string, 0, len(obsolete))
-	for pkg := range obsolete {
-		obsol = append(obsol, pkg)
+	obsol := make([]string, 0, len(pkgs)-len(keep))
+	for _, pkg := range pkgs {
+		if _, ok := keep[pkg.Name]; !ok {
+			obsol = append(obsol, pkg.Name)
+		}
 	}
 
+	sort.Strings(obsol)
+
 	return obsol
 }
 
+// depName returns the name part of a dependency e.g. "glibc" for
+// "glibc>=2.26".
+func depName(dep string) string {
+	if i := strings.IndexAny(dep, "<>="); i >= 0 {
+		return dep[:i]
+	}
+	return dep
+}
+
+// parsePackage parses a desc file in the libalpm database format. Fields
+// unknown to the parser are skipped.
 func parsePackage(tarRdr io.Reader, pkg *model.Package) error {
 	rdr := bufio.NewReader(tarRdr)
 	var curr *string
@@ -355,10 +629,18 @@ func parsePackage(tarRdr io.Reader, pkg *model.Package) error {
 				return err
 			}
 
-			break
+			if line == "" {
+				break
+			}
 		}
 
-		line = line[:len(line)-1]
+		line = strings.TrimSuffix(line, "\n")
+
+		if isField(line) {
+			curr = nil
+			currTime = nil
+			currSlice = nil
+		}
 
 		switch line {
 		case `%FILENAME%`:
@@ -371,6 +653,8 @@ func parsePackage(tarRdr io.Reader, pkg *model.Package) error {
 			curr = &pkg.Version
 		case `%DESC%`:
 			curr = &pkg.Desc
+		case `%GROUPS%`:
+			currSlice = &pkg.Groups
 		case `%CSIZE%`:
 			curr = &pkg.CSize
 		case `%ISIZE%`:
@@ -379,22 +663,34 @@ func parsePackage(tarRdr io.Reader, pkg *model.Package) error {
 			curr = &pkg.MD5Sum
 		case `%SHA256SUM%`:
 			curr = &pkg.SHA256Sum
+		case `%PGPSIG%`:
+			curr = &pkg.PGPSig
 		case `%URL%`:
 			curr = &pkg.URL
 		case `%LICENSE%`:
-			curr = &pkg.License
+			currSlice = &pkg.License
 		case `%ARCH%`:
 			curr = &pkg.Arch
 		case `%BUILDDATE%`:
 			currTime = &pkg.BuildDate
 		case `%PACKAGER%`:
 			curr = &pkg.Packager
+		case `%REPLACES%`:
+			currSlice = &pkg.Replaces
+		case `%CONFLICTS%`:
+			currSlice = &pkg.Conflicts
+		case `%PROVIDES%`:
+			currSlice = &pkg.Provides
 		case `%DEPENDS%`:
 			currSlice = &pkg.Depends
-		case `%MAKEDEPENDS%`:
-			currSlice = &pkg.MakeDepends
 		case `%OPTDEPENDS%`:
 			currSlice = &pkg.OptDepends
+		case `%MAKEDEPENDS%`:
+			currSlice = &pkg.MakeDepends
+		case `%CHECKDEPENDS%`:
+			currSlice = &pkg.CheckDepends
+		case `%XDATA%`:
+			currSlice = &pkg.XData
 		case ``:
 			curr = nil
 			currTime = nil
@@ -402,6 +698,8 @@ func parsePackage(tarRdr io.Reader, pkg *model.Package) error {
 		default:
 			if curr != nil {
 				*curr = line
+				// single value fields only have one line.
+				curr = nil
 			}
 
 			if currTime != nil {
@@ -410,6 +708,7 @@ func parsePackage(tarRdr io.Reader, pkg *model.Package) error {
 					return err
 				}
 				*currTime = time.Unix(i, 0)
+				currTime = nil
 			}
 
 			if currSlice != nil {
@@ -421,228 +720,75 @@ func parsePackage(tarRdr io.Reader, pkg *model.Package) error {
 	return nil
 }
 
+// isField returns true if the line is a field name of a desc file e.g.
+// "%NAME%".
+func isField(line string) bool {
+	return len(line) > 2 && strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%")
+}
+
 // Package returns a named package from the repo.
 func (r *Repo) Package(name, arch string, files bool) (*model.Package, error) {
-	r.rwLock.RLock()
-	defer r.rwLock.RUnlock()
-
-	f, err := os.Open(r.FilesDB(arch))
+	idx, err := r.index(arch)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-
-	gzf, err := gzip.NewReader(f)
-	if err != nil {
-		return nil, err
-	}
-
-	tarR := tar.NewReader(gzf)
-
-	found := false
-	foundFiles := false
-	pkg := &model.Package{
-		Depends:     []string{},
-		OptDepends:  []string{},
-		MakeDepends: []string{},
-		Files:       []string{},
-	}
-
-	for {
-		if found && (foundFiles || !files) {
-			break
-		}
-
-		header, err := tarR.Next()
-		if err != nil {
-			if err != io.EOF {
-				return nil, err
-			}
 
-			break
-		}
-
-		switch header.Typeflag {
-		case tar.TypeReg:
-			pkgName, _ := splitNameVersion(header.Name)
-
-			if pkgName == name {
-				if strings.HasSuffix(header.Name, "/desc") {
-					err := parsePackage(tarR, pkg)
-					if err != nil {
-						return nil, err
-					}
-					found = true
-				}
-				if strings.HasSuffix(header.Name, "/files") && files {
-					content, err := ioutil.ReadAll(tarR)
-					if err != nil {
-						return nil, err
-					}
-
-					pkg.Files = strings.Split(string(content), "\n")
-					pkg.Files = pkg.Files[1:]
-					if pkg.Files[len(pkg.Files)-1] == "" {
-						pkg.Files = pkg.Files[:len(pkg.Files)-1]
-					}
-					foundFiles = true
-				}
-			}
-		}
-	}
-
-	if pkg.Name == "" {
+	entry, ok := idx.entries[name]
+	if !ok {
 		return nil, nil
 	}
 
-	return pkg, nil
+	return entry.Package(files), nil
 }
 
 // Packages returns a list of all packages in the repo.
 func (r *Repo) Packages(arch string, files bool) ([]*model.Package, error) {
-	r.rwLock.RLock()
-	defer r.rwLock.RUnlock()
-
-	var pkgs []*model.Package
-
-	f, err := os.Open(r.FilesDB(arch))
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	gzf, err := gzip.NewReader(f)
+	idx, err := r.index(arch)
 	if err != nil {
 		return nil, err
 	}
 
-	tarR := tar.NewReader(gzf)
-
-	var pkg *model.Package
-
-	for {
-		header, err := tarR.Next()
-		if err != nil {
-			if err != io.EOF {
-				return nil, err
-			}
-
-			break
-		}
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if pkg != nil {
-				pkgs = append(pkgs, pkg)
-			}
-
-			pkg = &model.Package{
-				Depends:     []string{},
-				OptDepends:  []string{},
-				MakeDepends: []string{},
-				Files:       []string{},
-			}
-		case tar.TypeReg:
-			if strings.HasSuffix(header.Name, "/desc") {
-				err := parsePackage(tarR, pkg)
-				if err != nil {
-					return nil, err
-				}
-			}
-			if strings.HasSuffix(header.Name, "/files") && files {
-				content, err := ioutil.ReadAll(tarR)
-				if err != nil {
-					return nil, err
-				}
-
-				pkg.Files = strings.Split(string(content), "\n")
-				pkg.Files = pkg.Files[1:]
-				if pkg.Files[len(pkg.Files)-1] == "" {
-					pkg.Files = pkg.Files[:len(pkg.Files)-1]
-				}
-			}
-		}
-	}
-
-	if pkg != nil {
-		pkgs = append(pkgs, pkg)
+	pkgs := make([]*model.Package, 0, len(idx.sorted))
+	for _, entry := range idx.sorted {
+		pkgs = append(pkgs, entry.Package(files))
 	}
 
 	return pkgs, nil
 }
 
-// TODO: what is a needed dep?
-type pkgDep struct {
-	makedepends []string
-	depends     []string
-	optdepends  []string
-}
-
-func (r *Repo) readPkgMap(arch string) (map[string]*pkgDep, error) {
-	pkgMap := make(map[string]*pkgDep)
-
-	f, err := os.Open(r.DB(arch))
+// Groups returns the groups of an arch mapped to the names of their member
+// packages.
+func (r *Repo) Groups(arch string) (map[string][]string, error) {
+	idx, err := r.index(arch)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	gzf, err := gzip.NewReader(f)
-	if err != nil {
-		return nil, err
+	groups := make(map[string][]string)
+	for _, entry := range idx.sorted {
+		for _, group := range entry.pkg.Groups {
+			groups[group] = append(groups[group], entry.pkg.Name)
+		}
 	}
 
-	tarR := tar.NewReader(gzf)
-
-	for {
-		header, err := tarR.Next()
-		if err == io.EOF {
-			break
-		}
+	return groups, nil
+}
 
-		if err != nil {
-			return nil, err
-		}
+// Group returns the packages of an arch which are members of a group.
+func (r *Repo) Group(group, arch string) ([]*model.Package, error) {
+	idx, err := r.index(arch)
+	if err != nil {
+		return nil, err
+	}
 
-		switch header.Typeflag {
-		case tar.TypeReg:
-			if strings.HasSuffix(header.Name, "/depends") {
-				rdr := bufio.NewReader(tarR)
-				pkg := &pkgDep{}
-				var curr []string
-				eof := false
-				for {
-					line, err := rdr.ReadString('\n')
-					if err != nil {
-						if err != io.EOF {
-							return nil, err
-						}
-
-						eof = true
-					}
-
-					switch line {
-					case `%DEPENDS%`:
-						curr = pkg.depends
-					case `%MAKEDEPENDS%`:
-						curr = pkg.makedepends
-					case `%OPTDEPENDS%`:
-						curr = pkg.optdepends
-					case ``:
-						if eof {
-							break
-						}
-					default:
-						curr = append(curr, line)
-					}
-				}
-				pkgMap[path.Dir(header.Name)] = pkg
-				fmt.Println(pkg)
-			}
+	pkgs := []*model.Package{}
+	for _, entry := range idx.sorted {
+		if util.StrContains(group, entry.pkg.Groups) {
+			pkgs = append(pkgs, entry.Package(false))
 		}
 	}
 
-	return pkgMap, nil
+	return pkgs, nil
 }
 
 // turn "zlib-1.2.8-4/" into ("zlib", "1.2.8-4").
@@ -4,7 +4,9 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mikkeloscar/gopkgbuild"
 	"github.com/mikkeloscar/maze/model"
@@ -105,6 +107,10 @@ func TestAdd(t *testing.T) {
 	err = repo2.Add(pkgPaths)
 	assert.NoError(t, err, "should not fail")
 
+	pkg, err := repo2.Package("ca-certificates", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.NotNil(t, pkg, "should not be nil")
+
 	err = repo2.Add([]string{})
 	assert.NoError(t, err, "should not fail")
 
@@ -141,11 +147,66 @@ func TestRemove(t *testing.T) {
 	err = repo2.Remove([]string{"ca-certificates"}, "x86_64")
 	assert.NoError(t, err, "should not fail")
 
+	pkgs, err := repo2.Packages("x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, pkgs, 0, "should have length 0")
+
+	_, err = os.Stat(pkgPaths[0])
+	assert.True(t, os.IsNotExist(err), "should be true")
+
 	// clean
 	err = repo2.ClearPath()
 	assert.NoError(t, err, "should not fail")
 }
 
+// Test adding and removing archs.
+func TestAddRemoveArch(t *testing.T) {
+	err := repo2.InitDir()
+	assert.NoError(t, err, "should not fail")
+
+	pkgPaths := []string{
+		"test_files/repo2/x86_64/ca-certificates-20150402-1-any.pkg.tar.xz",
+	}
+
+	cmd := exec.Command(
+		"cp",
+		"test_files/repo1/x86_64/ca-certificates-20150402-1-any.pkg.tar.xz",
+		"test_files/repo2/x86_64/ca-certificates-20150402-1-any.pkg.tar.xz")
+	err = cmd.Run()
+	assert.NoError(t, err, "should not fail")
+
+	err = repo2.Add(pkgPaths)
+	assert.NoError(t, err, "should not fail")
+
+	// 'any' packages are added to the new arch
+	err = repo2.AddArch("aarch64")
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, []string{"x86_64", "aarch64"}, repo2.Archs, "should be equal")
+
+	pkg, err := repo2.Package("ca-certificates", "aarch64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.NotNil(t, pkg, "should not be nil")
+
+	_, err = os.Stat(path.Join(repo2.PathDeep("aarch64"), pkg.FileName))
+	assert.NoError(t, err, "should not fail")
+
+	err = repo2.RemoveArch("x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, []string{"aarch64"}, repo2.Archs, "should be equal")
+
+	_, err = os.Stat(repo2.PathDeep("x86_64"))
+	assert.True(t, os.IsNotExist(err), "should be true")
+
+	// the last arch can't be removed
+	err = repo2.RemoveArch("aarch64")
+	assert.Error(t, err, "should fail")
+
+	// clean
+	repo2.Archs = []string{"x86_64"}
+	err = repo2.ClearPath()
+	assert.NoError(t, err, "should not fail")
+}
+
 // Test IsNew.
 func TestIsNew(t *testing.T) {
 	pkg := "ca-certificates"
@@ -175,24 +236,39 @@ func TestIsNew(t *testing.T) {
 	assert.True(t, new, "should be true")
 }
 
-func Testobsolete(t *testing.T) {
-	whiteList := []string{
-		"a",
-		"b",
-		"c",
-		"d",
-	}
+// Test IsNewFilename.
+func TestIsNewFilename(t *testing.T) {
+	new, err := repo1.IsNewFilename("ca-certificates-20150402-1-any.pkg.tar.xz")
+	assert.NoError(t, err, "should not fail")
+	assert.False(t, new, "should be false")
 
-	pkgMap := map[string]*pkgDep{
-		"a": &pkgDep{
-			depends: []string{"c"},
-		},
-		"b": &pkgDep{},
+	new, err = repo1.IsNewFilename("ca-certificates-20150402-2-any.pkg.tar.xz")
+	assert.NoError(t, err, "should not fail")
+	assert.True(t, new, "should be true")
+
+	_, err = repo1.IsNewFilename("ca-certificates.pkg.tar.xz")
+	assert.Error(t, err, "should fail")
+}
+
+func TestObsolete(t *testing.T) {
+	wanted := []string{"a", "b", "split"}
+
+	pkgs := []*model.Package{
+		{Name: "a", Base: "a", Depends: []string{"c>=1.0"}},
+		{Name: "b", Base: "b", MakeDepends: []string{"libd.so"}},
+		{Name: "c", Base: "c", Depends: []string{"e"}},
+		{Name: "d", Base: "d", Provides: []string{"libd.so=1-64"}},
+		{Name: "e", Base: "e"},
+		{Name: "f", Base: "f", Depends: []string{"g"}},
+		{Name: "g", Base: "g"},
+		{Name: "split-a", Base: "split"},
 	}
 
-	obsolete := repo1.obsolete(whiteList, pkgMap)
-	assert.Len(t, obsolete, 1, "should have length 1")
-	assert.Equal(t, "d", obsolete[0], "should be equal")
+	obsol := obsolete(wanted, pkgs)
+	assert.Equal(t, []string{"f", "g"}, obsol, "should be equal")
+
+	obsol = obsolete(nil, pkgs)
+	assert.Len(t, obsol, len(pkgs), "should have all packages")
 }
 
 func TestPackages(t *testing.T) {
@@ -217,6 +293,150 @@ func TestPackage(t *testing.T) {
 	assert.Nil(t, pkg, "should be nil")
 }
 
+// Test parsing all fields of a desc file.
+func TestParsePackage(t *testing.T) {
+	desc := `%FILENAME%
+foo-1.0-1-x86_64.pkg.tar.zst
+
+%NAME%
+foo
+
+%BASE%
+foo-base
+
+%VERSION%
+1.0-1
+
+%DESC%
+test package
+
+%GROUPS%
+devel
+base-devel
+
+%CSIZE%
+1024
+
+%ISIZE%
+4096
+
+%MD5SUM%
+d41d8cd98f00b204e9800998ecf8427e
+
+%SHA256SUM%
+e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+
+%PGPSIG%
+c2ln
+
+%URL%
+https://example.org
+
+%LICENSE%
+MIT
+Apache
+
+%ARCH%
+x86_64
+
+%BUILDDATE%
+1428007012
+
+%PACKAGER%
+maze <maze@example.org>
+
+%REPLACES%
+foo-old
+
+%CONFLICTS%
+foo-git
+
+%PROVIDES%
+libfoo.so=1-64
+
+%XDATA%
+pkgtype=pkg
+
+%UNKNOWN%
+unknown
+
+%DEPENDS%
+glibc
+
+%OPTDEPENDS%
+bar: for bar support
+
+%MAKEDEPENDS%
+cmake
+
+%CHECKDEPENDS%
+python-pytest
+`
+
+	pkg := &model.Package{}
+	err := parsePackage(strings.NewReader(desc), pkg)
+	assert.NoError(t, err, "should not fail")
+
+	assert.Equal(t, &model.Package{
+		FileName:     "foo-1.0-1-x86_64.pkg.tar.zst",
+		Name:         "foo",
+		Base:         "foo-base",
+		Version:      "1.0-1",
+		Desc:         "test package",
+		Groups:       []string{"devel", "base-devel"},
+		CSize:        "1024",
+		ISize:        "4096",
+		MD5Sum:       "d41d8cd98f00b204e9800998ecf8427e",
+		SHA256Sum:    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		PGPSig:       "c2ln",
+		URL:          "https://example.org",
+		License:      []string{"MIT", "Apache"},
+		Arch:         "x86_64",
+		BuildDate:    time.Unix(1428007012, 0),
+		Packager:     "maze <maze@example.org>",
+		Replaces:     []string{"foo-old"},
+		Conflicts:    []string{"foo-git"},
+		Provides:     []string{"libfoo.so=1-64"},
+		Depends:      []string{"glibc"},
+		OptDepends:   []string{"bar: for bar support"},
+		MakeDepends:  []string{"cmake"},
+		CheckDepends: []string{"python-pytest"},
+		XData:        []string{"pkgtype=pkg"},
+	}, pkg, "should be equal")
+}
+
+// Test listing groups and group members.
+func TestGroups(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "groups"}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	err = r.Add([]string{
+		writeTestPkg(t, r.Path(), "foo", "1.0-1", "x86_64", "group = devel"),
+		writeTestPkg(t, r.Path(), "bar", "1.0-1", "any", "group = devel", "group = extra"),
+		writeTestPkg(t, r.Path(), "baz", "1.0-1", "x86_64"),
+	})
+	assert.NoError(t, err, "should not fail")
+
+	groups, err := r.Groups("x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, map[string][]string{
+		"devel": {"bar", "foo"},
+		"extra": {"bar"},
+	}, groups, "should be equal")
+
+	pkgs, err := r.Group("devel", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, pkgs, 2, "should have length 2")
+	assert.Equal(t, []string{"devel", "extra"}, pkgs[0].Groups, "should be equal")
+
+	pkgs, err = r.Group("missing", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, pkgs, 0, "should be empty")
+}
+
 func TestValidRepoName(t *testing.T) {
 	assert.True(t, ValidRepoName("test"), "should be true")
 	assert.True(t, ValidRepoName("test123"), "should be true")
@@ -230,3 +450,14 @@ func TestValidRepoName(t *testing.T) {
 	assert.False(t, ValidRepoName("test="), "should be false")
 	assert.False(t, ValidRepoName("te st"), "should be false")
 }
+
+func TestValidArch(t *testing.T) {
+	assert.True(t, ValidArch("x86_64"), "should be true")
+	assert.True(t, ValidArch("i686"), "should be true")
+	assert.True(t, ValidArch("aarch64"), "should be true")
+	assert.True(t, ValidArch("armv7h"), "should be true")
+	assert.False(t, ValidArch("any"), "should be false")
+	assert.False(t, ValidArch("foo"), "should be false")
+	assert.True(t, ValidArchs([]string{"x86_64", "aarch64"}), "should be true")
+	assert.False(t, ValidArchs([]string{"x86_64", "foo"}), "should be false")
+}
@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"strings"
+
+	"github.com/mikkeloscar/maze/model"
+)
+
+// Scores of the ways a search term can match a package.
+const (
+	scoreNameExact     = 100
+	scoreNamePrefix    = 50
+	scoreName          = 30
+	scoreProvidesExact = 20
+	scoreGroupExact    = 15
+	scoreProvides      = 10
+	scoreGroup         = 5
+	scoreDesc          = 3
+)
+
+// SearchQuery is a parsed package search query. A package matches if every
+// term of the query matches its name, description, provides or groups.
+type SearchQuery struct {
+	terms []string
+	// Packager limits the search to packages where the packager contains
+	// the string.
+	Packager string
+}
+
+// NewSearchQuery parses a search query. Terms are separated by whitespace
+// and matched case insensitively.
+func NewSearchQuery(query string) *SearchQuery {
+	return &SearchQuery{
+		terms: strings.Fields(strings.ToLower(query)),
+	}
+}
+
+// Empty returns true if the query doesn't have any terms.
+func (q *SearchQuery) Empty() bool {
+	return len(q.terms) == 0
+}
+
+// score returns the search score of a package or 0 if it doesn't match.
+func (q *SearchQuery) score(pkg *model.Package) int {
+	if q.Packager != "" && !strings.Contains(strings.ToLower(pkg.Packager), strings.ToLower(q.Packager)) {
+		return 0
+	}
+
+	name := strings.ToLower(pkg.Name)
+	desc := strings.ToLower(pkg.Desc)
+
+	total := 0
+
+	for _, term := range q.terms {
+		score := 0
+
+		switch {
+		case name == term:
+			score += scoreNameExact
+		case strings.HasPrefix(name, term):
+			score += scoreNamePrefix
+		case strings.Contains(name, term):
+			score += scoreName
+		}
+
+		for _, provide := range pkg.Provides {
+			provide = strings.ToLower(depName(provide))
+			if provide == term {
+				score += scoreProvidesExact
+				break
+			}
+			if strings.Contains(provide, term) {
+				score += scoreProvides
+				break
+			}
+		}
+
+		for _, group := range pkg.Groups {
+			group = strings.ToLower(group)
+			if group == term {
+				score += scoreGroupExact
+				break
+			}
+			if strings.Contains(group, term) {
+				score += scoreGroup
+				break
+			}
+		}
+
+		if strings.Contains(desc, term) {
+			score += scoreDesc
+		}
+
+		if score == 0 {
+			return 0
+		}
+
+		total += score
+	}
+
+	return total
+}
+
+// Search returns the packages of an arch matching the query.
+func (r *Repo) Search(arch string, q *SearchQuery) ([]*model.SearchResult, error) {
+	idx, err := r.index(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*model.SearchResult
+
+	for _, entry := range idx.sorted {
+		score := q.score(entry.pkg)
+		if score == 0 {
+			continue
+		}
+
+		results = append(results, &model.SearchResult{
+			Owner:   r.Owner,
+			Repo:    r.Name,
+			Arch:    arch,
+			Score:   score,
+			Package: entry.Package(false),
+		})
+	}
+
+	return results, nil
+}
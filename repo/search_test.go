@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test searching packages.
+func TestSearch(t *testing.T) {
+	r := NewRepo(&model.Repo{Owner: "owner", Name: "search"}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	err = r.Add([]string{
+		writeTestPkg(t, r.Path(), "foo", "1.0-1", "x86_64"),
+		writeTestPkg(t, r.Path(), "foobar", "1.0-1", "x86_64", "group = devel", "provides = libfoo.so"),
+		writeTestPkg(t, r.Path(), "bar", "1.0-1", "x86_64", "provides = foo=1.0"),
+		writeTestPkg(t, r.Path(), "baz", "1.0-1", "x86_64"),
+	})
+	assert.NoError(t, err, "should not fail")
+
+	scores := func(q *SearchQuery) map[string]int {
+		results, err := r.Search("x86_64", q)
+		assert.NoError(t, err, "should not fail")
+
+		scores := make(map[string]int, len(results))
+		for _, result := range results {
+			scores[result.Package.Name] = result.Score
+		}
+		return scores
+	}
+
+	assert.Equal(t, map[string]int{
+		"foo":    scoreNameExact + scoreDesc,
+		"foobar": scoreNamePrefix + scoreProvides + scoreDesc,
+		"bar":    scoreProvidesExact,
+	}, scores(NewSearchQuery("FOO")), "should be equal")
+
+	assert.Equal(t, map[string]int{
+		"foobar": scoreGroupExact,
+	}, scores(NewSearchQuery("devel")), "should be equal")
+
+	assert.Equal(t, map[string]int{
+		"foobar": scoreNamePrefix + scoreProvides + scoreDesc + scoreGroupExact,
+	}, scores(NewSearchQuery("foo devel")), "should be equal")
+
+	q := NewSearchQuery("foo")
+	q.Packager = "nobody"
+	assert.Len(t, scores(q), 0, "should be empty")
+
+	q.Packager = "MAZE"
+	assert.Len(t, scores(q), 3, "should be equal")
+
+	assert.True(t, NewSearchQuery("  ").Empty(), "should be empty")
+}
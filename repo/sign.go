@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+var keyConfig = &packet.Config{
+	Algorithm: packet.PubKeyAlgoEdDSA,
+}
+
+// NewSigningKey generates a new armored private signing key for the repo
+// owner/name.
+func NewSigningKey(owner, name string) (string, error) {
+	entity, err := openpgp.NewEntity(
+		fmt.Sprintf("%s/%s", owner, name),
+		"maze repository signing key",
+		"",
+		keyConfig,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		return "", err
+	}
+
+	err = entity.SerializePrivate(w, nil)
+	if err != nil {
+		return "", err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// signingEntity returns the signing key of the repo. If the repo doesn't
+// have a signing key nil is returned.
+func (r *Repo) signingEntity() (*openpgp.Entity, error) {
+	if r.SigningKey == "" {
+		return nil, nil
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(r.SigningKey))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("expected one signing key, found %d", len(entities))
+	}
+
+	return entities[0], nil
+}
+
+// PublicKey returns the armored public part of the repo signing key. If the
+// repo doesn't have a signing key nil is returned.
+func (r *Repo) PublicKey() ([]byte, error) {
+	entity, err := r.signingEntity()
+	if err != nil || entity == nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = entity.Serialize(w)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// signFile writes a detached signature of file to file.sig.
+func signFile(st Storage, entity *openpgp.Entity, file string) error {
+	f, err := st.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sig bytes.Buffer
+
+	err = openpgp.DetachSign(&sig, entity, f, nil)
+	if err != nil {
+		return err
+	}
+
+	return st.Put(file+".sig", &sig)
+}
+
+// signPkgs writes detached signatures for packages which aren't already
+// signed. The packages are local files, e.g. uploads. Nothing is done if the
+// repo doesn't have a signing key.
+func (r *Repo) signPkgs(pkgPaths []string) error {
+	entity, err := r.signingEntity()
+	if err != nil || entity == nil {
+		return err
+	}
+
+	for _, pkg := range pkgPaths {
+		_, err := os.Stat(pkg + ".sig")
+		if err == nil {
+			continue
+		}
+
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		err = signFile(localFS, entity, pkg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
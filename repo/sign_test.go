@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// checkSig verifies the detached signature file.sig against keyring.
+func checkSig(t *testing.T, keyring openpgp.EntityList, file string) {
+	f, err := os.Open(file)
+	assert.NoError(t, err, "should not fail")
+	defer f.Close()
+
+	sig, err := os.Open(file + ".sig")
+	assert.NoError(t, err, "should not fail")
+	defer sig.Close()
+
+	_, err = openpgp.CheckDetachedSignature(keyring, f, sig, nil)
+	assert.NoError(t, err, "should not fail")
+}
+
+// Test that packages and dbs are signed when adding packages.
+func TestAddSigned(t *testing.T) {
+	key, err := NewSigningKey("owner", "repo3")
+	assert.NoError(t, err, "should not fail")
+
+	repo3 := NewRepo(&model.Repo{Name: "repo3", SigningKey: key}, repoStorage)
+
+	pubKey, err := repo3.PublicKey()
+	assert.NoError(t, err, "should not fail")
+
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(pubKey))
+	assert.NoError(t, err, "should not fail")
+
+	err = repo3.InitDir()
+	assert.NoError(t, err, "should not fail")
+
+	err = repo3.InitEmptyDBs()
+	assert.NoError(t, err, "should not fail")
+
+	checkSig(t, keyring, repo3.DB("x86_64"))
+
+	pkgPath := "test_files/repo3/x86_64/ca-certificates-20150402-1-any.pkg.tar.xz"
+
+	cmd := exec.Command(
+		"cp",
+		"test_files/repo1/x86_64/ca-certificates-20150402-1-any.pkg.tar.xz",
+		pkgPath)
+	err = cmd.Run()
+	assert.NoError(t, err, "should not fail")
+
+	err = repo3.Add([]string{pkgPath})
+	assert.NoError(t, err, "should not fail")
+
+	checkSig(t, keyring, pkgPath)
+	checkSig(t, keyring, repo3.DB("x86_64"))
+	checkSig(t, keyring, repo3.FilesDB("x86_64"))
+
+	pkg, err := repo3.Package("ca-certificates", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.NotNil(t, pkg, "should not be nil")
+
+	// clean
+	err = repo3.ClearPath()
+	assert.NoError(t, err, "should not fail")
+}
+
+// Test that repos without a signing key don't have a public key.
+func TestPublicKeyUnsigned(t *testing.T) {
+	key, err := repo1.PublicKey()
+	assert.NoError(t, err, "should not fail")
+	assert.Nil(t, key, "should be nil")
+}
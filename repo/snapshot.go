@@ -0,0 +1,220 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/mikkeloscar/maze/model"
+)
+
+// SnapshotDateFormat is the format used for naming dated snapshots.
+const SnapshotDateFormat = "2006-01-02"
+
+var snapshotNamePatt = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d._-]*$`)
+
+var (
+	// ErrSnapshotExists is returned when creating a snapshot with a name
+	// already in use.
+	ErrSnapshotExists = errors.New("snapshot already exists")
+	// ErrSnapshotNotFound is returned when a snapshot doesn't exist.
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+// ValidSnapshotName returns true if name is a valid snapshot name.
+func ValidSnapshotName(name string) bool {
+	return snapshotNamePatt.MatchString(name)
+}
+
+// SnapshotPath returns the path to a snapshot of the repo.
+func (r *Repo) SnapshotPath(name string) string {
+	return path.Join(r.Path(), "snapshots", name)
+}
+
+// SnapshotPathDeep returns the path to an arch of a snapshot.
+func (r *Repo) SnapshotPathDeep(name, arch string) string {
+	return path.Join(r.SnapshotPath(name), arch)
+}
+
+// CreateSnapshot creates a read-only point-in-time copy of the dbs and
+// package files of all archs of the repo. Files are copied into the snapshot
+// using the cheapest copy of the storage, i.e. hardlinks on the local disk.
+// Since the repo never modifies files in place the snapshot is unaffected by
+// later changes to the repo.
+func (r *Repo) CreateSnapshot(name string) (*model.Snapshot, error) {
+	if !ValidSnapshotName(name) {
+		return nil, errors.New("invalid snapshot name")
+	}
+
+	unlock, err := r.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	dst := r.SnapshotPath(name)
+	if _, err := r.storage.Stat(dst); err == nil {
+		return nil, ErrSnapshotExists
+	}
+
+	// build the snapshot in a temporary dir and move it in place when
+	// complete.
+	tmp := path.Join(path.Dir(dst), fmt.Sprintf(".%s.%d", name, time.Now().UnixNano()))
+	defer r.storage.RemoveAll(tmp)
+
+	for _, arch := range r.Archs {
+		err := r.copyDir(r.PathDeep(arch), path.Join(tmp, arch))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = r.storage.Rename(tmp, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.snapshot(name)
+}
+
+// copyDir copies all files of the directory src into the directory dst.
+func (r *Repo) copyDir(src, dst string) error {
+	err := r.storage.MkdirAll(dst)
+	if err != nil {
+		return err
+	}
+
+	files, err := r.storage.List(src)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+
+		err := r.storage.Copy(path.Join(src, f.Name), path.Join(dst, f.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// snapshot returns the snapshot with the given name.
+func (r *Repo) snapshot(name string) (*model.Snapshot, error) {
+	info, err := r.storage.Stat(r.SnapshotPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, err
+	}
+
+	archs, err := r.storage.List(r.SnapshotPath(name))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &model.Snapshot{
+		Name:    name,
+		Created: info.ModTime.UTC(),
+		Archs:   make([]string, 0, len(archs)),
+	}
+
+	for _, arch := range archs {
+		if arch.IsDir {
+			snapshot.Archs = append(snapshot.Archs, arch.Name)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// Snapshot returns a named snapshot of the repo.
+func (r *Repo) Snapshot(name string) (*model.Snapshot, error) {
+	if !ValidSnapshotName(name) {
+		return nil, ErrSnapshotNotFound
+	}
+
+	return r.snapshot(name)
+}
+
+// Snapshots returns all snapshots of the repo sorted by name.
+func (r *Repo) Snapshots() ([]*model.Snapshot, error) {
+	dirs, err := r.storage.List(path.Join(r.Path(), "snapshots"))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*model.Snapshot, 0, len(dirs))
+
+	for _, dir := range dirs {
+		if !dir.IsDir || !ValidSnapshotName(dir.Name) {
+			continue
+		}
+
+		snapshot, err := r.snapshot(dir.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// DeleteSnapshot deletes a snapshot of the repo.
+func (r *Repo) DeleteSnapshot(name string) error {
+	if !ValidSnapshotName(name) {
+		return ErrSnapshotNotFound
+	}
+
+	if _, err := r.storage.Stat(r.SnapshotPath(name)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrSnapshotNotFound
+		}
+		return err
+	}
+
+	return r.storage.RemoveAll(r.SnapshotPath(name))
+}
+
+// PruneSnapshots deletes the oldest dated snapshots such that at most keep
+// dated snapshots remain. Snapshots not named by date are never pruned.
+func (r *Repo) PruneSnapshots(keep int) error {
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	var dated []string
+	for _, snapshot := range snapshots {
+		if _, err := time.Parse(SnapshotDateFormat, snapshot.Name); err == nil {
+			dated = append(dated, snapshot.Name)
+		}
+	}
+
+	if len(dated) <= keep {
+		return nil
+	}
+
+	// dates sort lexically, newest first.
+	sort.Sort(sort.Reverse(sort.StringSlice(dated)))
+
+	for _, name := range dated[keep:] {
+		err := r.storage.RemoveAll(r.SnapshotPath(name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
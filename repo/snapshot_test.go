@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that snapshots are unaffected by later changes to the repo.
+func TestSnapshot(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "snapshot"}, repoStorage)
+
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	pkg := writeTestPkg(t, r.Path(), "foo", "1.0-1", "x86_64")
+	err = r.Add([]string{pkg})
+	assert.NoError(t, err, "should not fail")
+
+	snapshot, err := r.CreateSnapshot("2026-10-17")
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, []string{"x86_64"}, snapshot.Archs, "should be equal")
+
+	_, err = r.CreateSnapshot("2026-10-17")
+	assert.Equal(t, ErrSnapshotExists, err, "should be equal")
+
+	_, err = r.CreateSnapshot("../foo")
+	assert.Error(t, err, "should fail")
+
+	pkg = writeTestPkg(t, r.Path(), "foo", "1.1-1", "x86_64")
+	err = r.Add([]string{pkg})
+	assert.NoError(t, err, "should not fail")
+
+	// snapshot still has the old db and package file
+	entries, err := readDB(localFS, path.Join(r.SnapshotPathDeep("2026-10-17", "x86_64"), "snapshot.files.tar.gz"))
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, "1.0-1", entries["foo"].pkg.Version, "should be equal")
+
+	_, err = os.Stat(path.Join(r.SnapshotPathDeep("2026-10-17", "x86_64"), entries["foo"].pkg.FileName))
+	assert.NoError(t, err, "should not fail")
+
+	link, err := os.Readlink(path.Join(r.SnapshotPathDeep("2026-10-17", "x86_64"), "snapshot.db"))
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, "snapshot.db.tar.gz", link, "should be equal")
+
+	_, err = r.CreateSnapshot("2026-10-18")
+	assert.NoError(t, err, "should not fail")
+	_, err = r.CreateSnapshot("release")
+	assert.NoError(t, err, "should not fail")
+
+	snapshots, err := r.Snapshots()
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, snapshots, 3, "should have length 3")
+
+	// only dated snapshots are pruned
+	err = r.PruneSnapshots(1)
+	assert.NoError(t, err, "should not fail")
+
+	snapshots, err = r.Snapshots()
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, snapshots, 2, "should have length 2")
+	assert.Equal(t, "2026-10-18", snapshots[0].Name, "should be equal")
+	assert.Equal(t, "release", snapshots[1].Name, "should be equal")
+
+	err = r.DeleteSnapshot("release")
+	assert.NoError(t, err, "should not fail")
+
+	err = r.DeleteSnapshot("release")
+	assert.Equal(t, ErrSnapshotNotFound, err, "should be equal")
+}
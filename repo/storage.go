@@ -2,27 +2,168 @@ package repo
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"strings"
+	"time"
 )
 
 // RepoStorage defines the repo storage basepath.
 var RepoStorage = ""
 
-// LoadRepoStorage checks if the path set by REPO_STORAGE is available and
-// tries to create it if it doesn't exist.
+// UploadStorage defines the local path where uploaded files are kept until
+// they are added to a repo.
+var UploadStorage = ""
+
+// LockStorage defines the local path where the lock files of the repos are
+// kept. Changes to a repo are only serialized across maze instances sharing
+// the repo storage if they share this path as well, e.g. on an NFS volume.
+var LockStorage = ""
+
+// DefaultStorage is the storage backend used by repos.
+var DefaultStorage Storage = &LocalStorage{}
+
+// FileInfo describes a file or directory in a storage.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// Storage is a backend for storing the package files and dbs of repos.
+// Files are addressed by slash separated paths. Directories are implicit;
+// writing a file creates its parent directories.
+type Storage interface {
+	// Open opens a file for reading.
+	Open(name string) (io.ReadCloser, error)
+
+	// Stat returns info about a file or directory. An error satisfying
+	// os.IsNotExist is returned if it doesn't exist.
+	Stat(name string) (*FileInfo, error)
+
+	// List lists the files and directories in a directory sorted by name.
+	// Listing a directory which doesn't exist returns an empty list.
+	List(dir string) ([]*FileInfo, error)
+
+	// Put writes the content of r to a file. The file is replaced
+	// atomically such that readers see either the old or the new
+	// content.
+	Put(name string, r io.Reader) error
+
+	// PutFile stores the local file src as name.
+	PutFile(name, src string) error
+
+	// GetFile retrieves a file to the local path dst.
+	GetFile(name, dst string) error
+
+	// Copy copies the file src to dst.
+	Copy(src, dst string) error
+
+	// Rename moves a file or directory from src to dst.
+	Rename(src, dst string) error
+
+	// Link makes name refer to target, a file in the same directory.
+	// Nothing is done if name already exists.
+	Link(target, name string) error
+
+	// Remove removes a file. Removing a file which doesn't exist is not
+	// an error.
+	Remove(name string) error
+
+	// RemoveAll removes a directory and everything in it.
+	RemoveAll(dir string) error
+
+	// MkdirAll creates a directory and its parents if the storage has
+	// real directories.
+	MkdirAll(dir string) error
+
+	// URL returns a URL where the file can be downloaded directly without
+	// going through maze. An empty string is returned if the storage
+	// doesn't support it.
+	URL(name string, expires time.Duration) (string, error)
+}
+
+// localFS is used for accessing local files such as uploads and sync dbs.
+var localFS Storage = &LocalStorage{}
+
+// LoadRepoStorage sets up the storage backend selected by STORAGE. The
+// default 'local' backend stores repos in the directory set by
+// REPO_STORAGE. The 's3' backend stores repos in an S3 compatible bucket
+// configured by the S3_* variables. REPO_STORAGE is then used as the key
+// prefix in the bucket. Uploads are kept in UPLOAD_STORAGE, which defaults
+// to REPO_STORAGE for the local backend. The lock files of the repos are kept
+// in LOCK_STORAGE, which defaults to UPLOAD_STORAGE for the local backend. As
+// the 's3' backend is meant to be shared by several instances, it requires
+// LOCK_STORAGE to be set to a path shared by all of them.
 func LoadRepoStorage() error {
 	RepoStorage = os.Getenv("REPO_STORAGE")
+	UploadStorage = os.Getenv("UPLOAD_STORAGE")
+	LockStorage = os.Getenv("LOCK_STORAGE")
+
+	switch os.Getenv("STORAGE") {
+	case "", "local":
+		DefaultStorage = &LocalStorage{}
+		if UploadStorage == "" {
+			UploadStorage = RepoStorage
+		}
+
+		if LockStorage == "" {
+			LockStorage = UploadStorage
+		}
+
+		err := checkDir(RepoStorage)
+		if err != nil {
+			return err
+		}
+	case "s3":
+		if LockStorage == "" {
+			return fmt.Errorf("s3 storage requires LOCK_STORAGE to be set to a path shared by all maze instances")
+		}
+
+		s3, err := NewS3Storage(&S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Insecure:  os.Getenv("S3_INSECURE") == "true",
+			Presign:   os.Getenv("S3_PRESIGN") != "false",
+		})
+		if err != nil {
+			return err
+		}
+		DefaultStorage = s3
+		RepoStorage = strings.Trim(RepoStorage, "/")
+		if UploadStorage == "" {
+			UploadStorage = path.Join(os.TempDir(), "maze")
+		}
+	default:
+		return fmt.Errorf("unknown storage backend: %s", os.Getenv("STORAGE"))
+	}
+
+	err := checkDir(UploadStorage)
+	if err != nil {
+		return err
+	}
+
+	return checkDir(LockStorage)
+}
 
-	f, err := os.Stat(RepoStorage)
+// checkDir checks if the local path is a directory and tries to create it if
+// it doesn't exist.
+func checkDir(dir string) error {
+	f, err := os.Stat(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return os.MkdirAll(RepoStorage, 0755)
+			return os.MkdirAll(dir, 0755)
 		}
 		return err
 	}
 
 	if !f.IsDir() {
-		return fmt.Errorf("repo storage path %s is not a directory", RepoStorage)
+		return fmt.Errorf("repo storage path %s is not a directory", dir)
 	}
 
 	return nil
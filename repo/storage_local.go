@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// LocalStorage stores files on the local disk. Names are used as file paths
+// as is.
+type LocalStorage struct{}
+
+// Open opens a file for reading.
+func (s *LocalStorage) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+// Stat returns info about a file or directory.
+func (s *LocalStorage) Stat(name string) (*FileInfo, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo(info), nil
+}
+
+func fileInfo(info os.FileInfo) *FileInfo {
+	return &FileInfo{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+}
+
+// List lists the files and directories in a directory. Symlinks are listed
+// as files.
+func (s *LocalStorage) List(dir string) ([]*FileInfo, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*FileInfo{}, nil
+		}
+		return nil, err
+	}
+
+	files := make([]*FileInfo, 0, len(infos))
+	for _, info := range infos {
+		files = append(files, fileInfo(info))
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	return files, nil
+}
+
+// Put writes the content of r to a temporary file which is moved in place
+// when complete.
+func (s *LocalStorage) Put(name string, r io.Reader) error {
+	dir, base := path.Split(name)
+
+	err := s.MkdirAll(dir)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+base)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Chmod(0644)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// PutFile hardlinks the local file src to name. If hardlinking isn't
+// possible the file is copied.
+func (s *LocalStorage) PutFile(name, src string) error {
+	return s.Copy(src, name)
+}
+
+// GetFile hardlinks the file to the local path dst. If hardlinking isn't
+// possible the file is copied.
+func (s *LocalStorage) GetFile(name, dst string) error {
+	return s.Copy(name, dst)
+}
+
+// Copy hardlinks src to dst, replacing dst if it exists. If hardlinking
+// isn't possible the file is copied.
+func (s *LocalStorage) Copy(src, dst string) error {
+	err := s.MkdirAll(path.Dir(dst))
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Link(src, dst)
+	if err != nil {
+		return copyFile(src, dst)
+	}
+
+	return nil
+}
+
+// Rename moves a file or directory from src to dst.
+func (s *LocalStorage) Rename(src, dst string) error {
+	err := s.MkdirAll(path.Dir(dst))
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(src, dst)
+}
+
+// Link creates a symlink to target.
+func (s *LocalStorage) Link(target, name string) error {
+	if _, err := os.Lstat(name); err == nil {
+		return nil
+	}
+
+	return os.Symlink(path.Base(target), name)
+}
+
+// Remove removes a file.
+func (s *LocalStorage) Remove(name string) error {
+	err := os.Remove(name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveAll removes a directory and everything in it.
+func (s *LocalStorage) RemoveAll(dir string) error {
+	return os.RemoveAll(dir)
+}
+
+// MkdirAll creates a directory and its parents.
+func (s *LocalStorage) MkdirAll(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	return os.MkdirAll(dir, 0755)
+}
+
+// URL returns an empty string as local files are served by maze.
+func (s *LocalStorage) URL(name string, expires time.Duration) (string, error) {
+	return "", nil
+}
+
+// copyFile copies the file src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
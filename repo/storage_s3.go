@@ -0,0 +1,335 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	log "github.com/sirupsen/logrus"
+)
+
+// S3Config configures an S3 compatible storage backend.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Insecure disables TLS for connecting to the endpoint.
+	Insecure bool
+	// Presign enables serving files via presigned URLs.
+	Presign bool
+}
+
+// S3Storage stores files as objects in an S3 compatible bucket. Names are
+// used as object keys. Directories are emulated by key prefixes.
+type S3Storage struct {
+	client  *minio.Client
+	bucket  string
+	presign bool
+}
+
+// NewS3Storage creates an S3 storage backend. The bucket is created if it
+// doesn't exist.
+func NewS3Storage(config *S3Config) (*S3Storage, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage requires an endpoint and a bucket")
+	}
+
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: !config.Insecure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		err = client.MakeBucket(ctx, config.Bucket, minio.MakeBucketOptions{Region: config.Region})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &S3Storage{
+		client:  client,
+		bucket:  config.Bucket,
+		presign: config.Presign,
+	}, nil
+}
+
+// key turns a name into an object key.
+func (s *S3Storage) key(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// prefix turns a directory name into a key prefix.
+func (s *S3Storage) prefix(dir string) string {
+	key := s.key(dir)
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+// notExist converts 'not found' errors into errors satisfying os.IsNotExist.
+func notExist(op, name string, err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return err
+}
+
+// Open opens an object for reading.
+func (s *S3Storage) Open(name string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(context.Background(), s.bucket, s.key(name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, notExist("open", name, err)
+	}
+
+	_, err = obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, notExist("open", name, err)
+	}
+
+	return obj, nil
+}
+
+// Stat returns info about an object. If there is no object with the name but
+// objects with the name as prefix, it's reported as a directory with the
+// modification time of the first object.
+func (s *S3Storage) Stat(name string) (*FileInfo, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	info, err := s.client.StatObject(ctx, s.bucket, s.key(name), minio.StatObjectOptions{})
+	if err == nil {
+		return &FileInfo{
+			Name:    path.Base(name),
+			Size:    info.Size,
+			ModTime: info.LastModified,
+		}, nil
+	}
+
+	err = notExist("stat", name, err)
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.prefix(name),
+		Recursive: true,
+		MaxKeys:   1,
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+
+		return &FileInfo{
+			Name:    path.Base(name),
+			ModTime: obj.LastModified,
+			IsDir:   true,
+		}, nil
+	}
+
+	return nil, err
+}
+
+// List lists the objects and prefixes directly below a prefix.
+func (s *S3Storage) List(dir string) ([]*FileInfo, error) {
+	files := []*FileInfo{}
+
+	for obj := range s.client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{
+		Prefix: s.prefix(dir),
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+
+		if strings.HasSuffix(obj.Key, "/") {
+			files = append(files, &FileInfo{
+				Name:  path.Base(obj.Key),
+				IsDir: true,
+			})
+			continue
+		}
+
+		files = append(files, &FileInfo{
+			Name:    path.Base(obj.Key),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	return files, nil
+}
+
+// Put uploads the content of r. Uploads are atomic in S3.
+func (s *S3Storage) Put(name string, r io.Reader) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.key(name), r, -1, minio.PutObjectOptions{})
+	return err
+}
+
+// PutFile uploads the local file src.
+func (s *S3Storage) PutFile(name, src string) error {
+	_, err := s.client.FPutObject(context.Background(), s.bucket, s.key(name), src, minio.PutObjectOptions{})
+	return err
+}
+
+// GetFile downloads an object to the local path dst.
+func (s *S3Storage) GetFile(name, dst string) error {
+	err := s.client.FGetObject(context.Background(), s.bucket, s.key(name), dst, minio.GetObjectOptions{})
+	return notExist("get", name, err)
+}
+
+// maxCopySize is the largest object which can be copied with a single
+// CopyObject request.
+const maxCopySize = 5 << 30
+
+// Copy copies an object server side. Objects larger than maxCopySize are
+// copied with a multipart copy.
+func (s *S3Storage) Copy(src, dst string) error {
+	ctx := context.Background()
+	dstOpts := minio.CopyDestOptions{Bucket: s.bucket, Object: s.key(dst)}
+	srcOpts := minio.CopySrcOptions{Bucket: s.bucket, Object: s.key(src)}
+
+	info, err := s.client.StatObject(ctx, s.bucket, s.key(src), minio.StatObjectOptions{})
+	if err != nil {
+		return notExist("copy", src, err)
+	}
+
+	if info.Size > maxCopySize {
+		_, err = s.client.ComposeObject(ctx, dstOpts, srcOpts)
+	} else {
+		_, err = s.client.CopyObject(ctx, dstOpts, srcOpts)
+	}
+	return notExist("copy", src, err)
+}
+
+// Rename copies an object, or all objects below a prefix, to dst and
+// removes the source objects. If copying a prefix fails the objects already
+// copied to dst are removed again.
+func (s *S3Storage) Rename(src, dst string) error {
+	err := s.Copy(src, dst)
+	if err == nil {
+		return s.Remove(src)
+	}
+
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var copied []string
+
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.prefix(src),
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			s.removeObjects(copied)
+			return obj.Err
+		}
+
+		name := path.Join(dst, strings.TrimPrefix(obj.Key, s.prefix(src)))
+
+		err := s.Copy(obj.Key, name)
+		if err != nil {
+			s.removeObjects(copied)
+			return err
+		}
+
+		copied = append(copied, name)
+	}
+
+	if len(copied) == 0 {
+		return &os.PathError{Op: "rename", Path: src, Err: os.ErrNotExist}
+	}
+
+	return s.RemoveAll(src)
+}
+
+// removeObjects removes the objects of a failed operation. Failures are
+// only logged as the error of the operation is returned instead.
+func (s *S3Storage) removeObjects(names []string) {
+	for _, name := range names {
+		err := s.Remove(name)
+		if err != nil {
+			log.Errorf("failed to remove object '%s': %s", name, err)
+		}
+	}
+}
+
+// Link copies target to name as S3 doesn't have links. The copy is always
+// refreshed since it doesn't follow changes to the target.
+func (s *S3Storage) Link(target, name string) error {
+	return s.Copy(path.Join(path.Dir(name), path.Base(target)), name)
+}
+
+// Remove removes an object.
+func (s *S3Storage) Remove(name string) error {
+	err := s.client.RemoveObject(context.Background(), s.bucket, s.key(name), minio.RemoveObjectOptions{})
+	if os.IsNotExist(notExist("remove", name, err)) {
+		return nil
+	}
+	return err
+}
+
+// RemoveAll removes all objects below a prefix.
+func (s *S3Storage) RemoveAll(dir string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.prefix(dir),
+		Recursive: true,
+	})
+
+	for err := range s.client.RemoveObjects(ctx, s.bucket, objects, minio.RemoveObjectsOptions{}) {
+		return err.Err
+	}
+
+	return nil
+}
+
+// MkdirAll does nothing as S3 doesn't have directories.
+func (s *S3Storage) MkdirAll(dir string) error {
+	return nil
+}
+
+// URL returns a presigned URL for downloading an object if presigning is
+// enabled.
+func (s *S3Storage) URL(name string, expires time.Duration) (string, error) {
+	if !s.presign {
+		return "", nil
+	}
+
+	u, err := s.client.PresignedGetObject(context.Background(), s.bucket, s.key(name), expires, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
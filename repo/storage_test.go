@@ -0,0 +1,175 @@
+package repo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStorage runs the storage operations used by repos against st. All
+// files are created below dir.
+func testStorage(t *testing.T, st Storage, dir string) {
+	defer st.RemoveAll(dir)
+
+	file := path.Join(dir, "a", "file")
+
+	_, err := st.Stat(file)
+	assert.True(t, os.IsNotExist(err), "should not exist")
+
+	_, err = st.Open(file)
+	assert.True(t, os.IsNotExist(err), "should not exist")
+
+	err = st.Put(file, strings.NewReader("content"))
+	assert.NoError(t, err, "should not fail")
+
+	info, err := st.Stat(file)
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, int64(7), info.Size, "should be equal")
+	assert.False(t, info.IsDir, "should be false")
+
+	f, err := st.Open(file)
+	assert.NoError(t, err, "should not fail")
+	content, err := ioutil.ReadAll(f)
+	assert.NoError(t, err, "should not fail")
+	assert.NoError(t, f.Close(), "should not fail")
+	assert.Equal(t, "content", string(content), "should be equal")
+
+	err = st.Put(file, strings.NewReader("replaced"))
+	assert.NoError(t, err, "should not fail")
+
+	err = st.Copy(file, path.Join(dir, "a", "copy"))
+	assert.NoError(t, err, "should not fail")
+
+	err = st.Link("file", path.Join(dir, "a", "link"))
+	assert.NoError(t, err, "should not fail")
+
+	files, err := st.List(path.Join(dir, "a"))
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, files, 3, "should have length 3")
+	assert.Equal(t, "copy", files[0].Name, "should be equal")
+	assert.Equal(t, "file", files[1].Name, "should be equal")
+	assert.Equal(t, "link", files[2].Name, "should be equal")
+
+	info, err = st.Stat(path.Join(dir, "a"))
+	assert.NoError(t, err, "should not fail")
+	assert.True(t, info.IsDir, "should be true")
+
+	err = st.Rename(path.Join(dir, "a"), path.Join(dir, "b"))
+	assert.NoError(t, err, "should not fail")
+
+	f, err = st.Open(path.Join(dir, "b", "link"))
+	assert.NoError(t, err, "should not fail")
+	content, err = ioutil.ReadAll(f)
+	assert.NoError(t, err, "should not fail")
+	assert.NoError(t, f.Close(), "should not fail")
+	assert.Equal(t, "replaced", string(content), "should be equal")
+
+	local := path.Join(os.TempDir(), "maze-storage-test")
+	defer os.Remove(local)
+
+	err = st.GetFile(path.Join(dir, "b", "copy"), local)
+	assert.NoError(t, err, "should not fail")
+
+	err = st.PutFile(path.Join(dir, "c"), local)
+	assert.NoError(t, err, "should not fail")
+
+	err = st.Remove(path.Join(dir, "c"))
+	assert.NoError(t, err, "should not fail")
+
+	err = st.Remove(path.Join(dir, "c"))
+	assert.NoError(t, err, "should not fail")
+
+	files, err = st.List(dir)
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, files, 1, "should have length 1")
+	assert.True(t, files[0].IsDir, "should be true")
+
+	err = st.RemoveAll(dir)
+	assert.NoError(t, err, "should not fail")
+
+	files, err = st.List(dir)
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, files, 0, "should have length 0")
+}
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, localFS, "test_files/storage")
+}
+
+// Test the S3 storage against an S3 compatible server such as MinIO e.g.:
+//
+//	S3_TEST_ENDPOINT=localhost:9000 S3_TEST_ACCESS_KEY=minioadmin \
+//	S3_TEST_SECRET_KEY=minioadmin go test ./repo
+func TestS3Storage(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set")
+	}
+
+	st, err := NewS3Storage(&S3Config{
+		Endpoint:  endpoint,
+		Bucket:    "maze-test",
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		Insecure:  true,
+		Presign:   true,
+	})
+	assert.NoError(t, err, "should not fail")
+
+	testStorage(t, st, "storage")
+
+	url, err := st.URL("storage/file", time.Minute)
+	assert.NoError(t, err, "should not fail")
+	assert.NotEmpty(t, url, "should not be empty")
+
+	// repo operations
+	r := NewRepoStorage(&model.Repo{Name: "s3"}, "repos", st)
+
+	err = r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	err = r.InitEmptyDBs()
+	assert.NoError(t, err, "should not fail")
+
+	pkg := writeTestPkg(t, r.UploadPath(), "foo", "1.0-1", "any")
+	err = r.Add([]string{pkg})
+	assert.NoError(t, err, "should not fail")
+
+	p, err := r.Package("foo", "x86_64", true)
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, "1.0-1", p.Version, "should be equal")
+
+	_, err = r.CreateSnapshot("s3")
+	assert.NoError(t, err, "should not fail")
+
+	f, err := st.Open(path.Join(r.SnapshotPathDeep("s3", "x86_64"), "s3.db"))
+	assert.NoError(t, err, "should not fail")
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(f)
+	assert.NoError(t, err, "should not fail")
+	f.Close()
+	assert.NotZero(t, buf.Len(), "should not be zero")
+}
+
+// Test that the s3 backend refuses to start without a shared lock path.
+func TestLoadRepoStorageS3Lock(t *testing.T) {
+	repoPath, uploadPath, lockPath, storage := RepoStorage, UploadStorage, LockStorage, DefaultStorage
+	defer func() {
+		RepoStorage, UploadStorage, LockStorage, DefaultStorage = repoPath, uploadPath, lockPath, storage
+	}()
+
+	t.Setenv("STORAGE", "s3")
+	t.Setenv("LOCK_STORAGE", "")
+
+	err := LoadRepoStorage()
+	assert.Error(t, err, "should fail")
+	assert.Contains(t, err.Error(), "LOCK_STORAGE", "should mention LOCK_STORAGE")
+}
@@ -0,0 +1,486 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// txn is a set of changes to the dbs and package files of a repo which is
+// published at once. New db content is staged outside of the arch dirs and
+// renamed in place for all archs when the txn is committed, such that
+// clients never see a partially updated repo. Package files are stored in
+// the arch dirs before publishing, which is invisible to clients as long as
+// no db references them. Files replaced by the txn are backed up and
+// restored if it fails. Replaced package files are only archived once the
+// new dbs are published. A txn must be used with the write lock of the repo
+// held.
+type txn struct {
+	r       *Repo
+	staging string
+	dbs     map[string]map[string]*dbEntry
+	backups map[string]string
+	// undo is run in reverse order if the txn is aborted.
+	undo []func() error
+	// published are the dbs renamed in place, restored together with
+	// their signatures if publishing fails.
+	published []string
+	// done is run in order once the txn is published.
+	done []func() error
+}
+
+// begin starts a new txn.
+func (r *Repo) begin() *txn {
+	return &txn{
+		r:       r,
+		staging: path.Join(r.Path(), ".staging", fmt.Sprintf("%d", time.Now().UnixNano())),
+		dbs:     make(map[string]map[string]*dbEntry),
+		backups: make(map[string]string),
+	}
+}
+
+// entries returns the entries of an arch as modified by the txn.
+func (t *txn) entries(arch string) (map[string]*dbEntry, error) {
+	if entries, ok := t.dbs[arch]; ok {
+		return entries, nil
+	}
+
+	entries, err := readDB(t.r.storage, t.r.FilesDB(arch))
+	if err != nil {
+		return nil, err
+	}
+
+	t.dbs[arch] = entries
+
+	return entries, nil
+}
+
+// backup copies a file of the repo to the staging area before it's replaced.
+// Nothing is done if the file doesn't exist or is already backed up.
+func (t *txn) backup(file string) error {
+	if _, ok := t.backups[file]; ok {
+		return nil
+	}
+
+	dst := path.Join(t.staging, "backup", strings.TrimPrefix(file, t.r.Path()))
+
+	err := t.r.storage.Copy(file, dst)
+	if err != nil {
+		if os.IsNotExist(err) {
+			t.backups[file] = ""
+			return nil
+		}
+		return err
+	}
+
+	t.backups[file] = dst
+
+	return nil
+}
+
+// restore restores a file replaced by the txn from its backup. A file which
+// didn't exist before the txn is removed.
+func (t *txn) restore(file string) error {
+	backup, ok := t.backups[file]
+	if !ok {
+		return nil
+	}
+
+	if backup == "" {
+		return t.r.storage.Remove(file)
+	}
+
+	return t.r.storage.Rename(backup, file)
+}
+
+// putPkgFile stores a local package file and its signature as dst in the
+// repo storage.
+func (t *txn) putPkgFile(src, dst string) error {
+	return t.storePkgFile(dst, func() error {
+		return t.r.putPkgFile(src, dst)
+	})
+}
+
+// copyPkgFile copies a package file and its signature within the repo
+// storage.
+func (t *txn) copyPkgFile(src, dst string) error {
+	return t.storePkgFile(dst, func() error {
+		return t.r.copyPkgFile(src, dst)
+	})
+}
+
+// storePkgFile backs up the package file dst and its signature before
+// storing a new version of them with store.
+func (t *txn) storePkgFile(dst string, store func() error) error {
+	files := []string{dst, dst + ".sig"}
+
+	for _, file := range files {
+		err := t.backup(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	t.undo = append(t.undo, func() error {
+		for _, file := range files {
+			err := t.restore(file)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return store()
+}
+
+// unarchive moves an archived package file of an arch and its signature
+// back to the arch dir. The files are moved back to the archive if the txn
+// is aborted.
+func (t *txn) unarchive(arch, file string) error {
+	for _, f := range []string{file, file + ".sig"} {
+		src, dst := path.Join(t.r.ArchivePath(arch), f), path.Join(t.r.PathDeep(arch), f)
+
+		err := t.r.storage.Rename(src, dst)
+		if err != nil {
+			if f != file && os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		t.undo = append(t.undo, func() error {
+			return t.r.storage.Rename(dst, src)
+		})
+	}
+
+	return nil
+}
+
+// add adds entries of package files stored in the arch dir to the db of an
+// arch. Replaced package files are archived when the txn is published.
+func (t *txn) add(arch string, added []*dbEntry) error {
+	entries, err := t.entries(arch)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range added {
+		if old, ok := entries[entry.pkg.Name]; ok && old.pkg.FileName != entry.pkg.FileName {
+			t.archive(arch, old.pkg.FileName)
+		}
+
+		entries[entry.pkg.Name] = entry
+	}
+
+	return nil
+}
+
+// remove removes packages from the db of an arch. The package files are
+// archived when the txn is published. The number of removed packages is
+// returned.
+func (t *txn) remove(arch string, pkgs []string) (int, error) {
+	entries, err := t.entries(arch)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+
+	for _, pkg := range pkgs {
+		if entry, ok := entries[pkg]; ok {
+			t.archive(arch, entry.pkg.FileName)
+			delete(entries, pkg)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// archive moves a package file of an arch to the archive once the txn is
+// published.
+func (t *txn) archive(arch, file string) {
+	t.done = append(t.done, func() error {
+		return t.r.archiveFile(arch, file)
+	})
+}
+
+// removeFile removes a file of an arch and its signature once the txn is
+// published.
+func (t *txn) removeFile(arch, file string) {
+	t.done = append(t.done, func() error {
+		return t.r.removeFile(arch, file)
+	})
+}
+
+// removeDir moves a directory of the repo to the staging area, where it's
+// removed once the txn is published.
+func (t *txn) removeDir(dir string) error {
+	dst := path.Join(t.staging, "removed", strings.TrimPrefix(dir, t.r.Path()))
+
+	err := t.r.storage.Rename(dir, dst)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	t.undo = append(t.undo, func() error {
+		return t.r.storage.Rename(dst, dir)
+	})
+
+	return nil
+}
+
+// archs returns the archs changed by the txn in sorted order.
+func (t *txn) archs() []string {
+	archs := make([]string, 0, len(t.dbs))
+	for arch := range t.dbs {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+	return archs
+}
+
+// stagedDBs returns the paths of the dbs of an arch and their staged
+// versions.
+func (t *txn) stagedDBs(arch string) map[string]string {
+	dbs := make(map[string]string, 2)
+	for _, db := range []string{t.r.DB(arch), t.r.FilesDB(arch)} {
+		dbs[db] = path.Join(t.staging, "dbs", arch, path.Base(db))
+	}
+	return dbs
+}
+
+// prepare writes the new dbs of all changed archs to the staging area.
+func (t *txn) prepare() error {
+	entity, err := t.r.signingEntity()
+	if err != nil {
+		return err
+	}
+
+	for _, arch := range t.archs() {
+		for db, staged := range t.stagedDBs(arch) {
+			err := writeDB(t.r.storage, staged, t.dbs[arch], db == t.r.FilesDB(arch))
+			if err != nil {
+				return err
+			}
+
+			if entity != nil {
+				err := signFile(t.r.storage, entity, staged)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// publish renames the staged dbs of all archs in place. If any rename fails
+// the dbs already published are restored.
+func (t *txn) publish() error {
+	entity, err := t.r.signingEntity()
+	if err != nil {
+		return err
+	}
+
+	for _, arch := range t.archs() {
+		dbs := t.stagedDBs(arch)
+
+		for _, db := range []string{t.r.FilesDB(arch), t.r.DB(arch)} {
+			err := t.publishDB(dbs[db], db, entity != nil)
+			if err != nil {
+				t.unpublish()
+				return err
+			}
+		}
+
+		invalidateIndex(t.r.FilesDB(arch))
+
+		err := t.r.linkDBs(arch, entity != nil)
+		if err != nil {
+			t.unpublish()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publishDB renames a staged db and, if signed, its signature in place. The
+// new signature is first moved next to the db under a temporary name, such
+// that the db and its signature are swapped by two back to back renames. The
+// db is never left without a signature; it's only paired with the signature
+// of the old version between the two renames. A new db is published after
+// its signature, and the old signature of a db which is no longer signed is
+// removed before the db is replaced.
+func (t *txn) publishDB(staged, db string, signed bool) error {
+	t.published = append(t.published, db)
+
+	for _, file := range []string{db + ".sig", db} {
+		err := t.backup(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !signed {
+		err := t.r.storage.Remove(db + ".sig")
+		if err != nil {
+			return err
+		}
+
+		return t.r.storage.Rename(staged, db)
+	}
+
+	// a new db is published after its signature.
+	if t.backups[db] == "" {
+		err := t.r.storage.Rename(staged+".sig", db+".sig")
+		if err != nil {
+			return err
+		}
+
+		return t.r.storage.Rename(staged, db)
+	}
+
+	err := t.r.storage.Rename(staged+".sig", pendingSig(db))
+	if err != nil {
+		return err
+	}
+
+	err = t.r.storage.Rename(staged, db)
+	if err != nil {
+		return err
+	}
+
+	return t.r.storage.Rename(pendingSig(db), db+".sig")
+}
+
+// pendingSig returns the temporary name of the new signature of a db while
+// it's published.
+func pendingSig(db string) string {
+	return db + ".sig.new"
+}
+
+// unpublish restores the dbs replaced by publish. As when publishing, a db
+// and its signature are restored back to back. It returns false if any db
+// couldn't be restored.
+func (t *txn) unpublish() bool {
+	ok := true
+
+	for i := len(t.published) - 1; i >= 0; i-- {
+		db := t.published[i]
+		t.r.storage.Remove(pendingSig(db))
+		for _, file := range []string{db, db + ".sig"} {
+			err := t.restore(file)
+			if err != nil {
+				log.Errorf("failed to restore '%s' of repo '%s/%s': %s", file, t.r.Owner, t.r.Name, err)
+				ok = false
+			}
+		}
+	}
+	t.published = nil
+
+	for _, arch := range t.archs() {
+		invalidateIndex(t.r.FilesDB(arch))
+	}
+
+	return ok
+}
+
+// abort undoes the changes made by the txn and removes the staging area. If
+// any change can't be undone the staging area is kept, as it holds the
+// backups needed to repair the repo by hand.
+func (t *txn) abort() {
+	ok := t.unpublish()
+
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		err := t.undo[i]()
+		if err != nil {
+			log.Errorf("failed to undo change of repo '%s/%s': %s", t.r.Owner, t.r.Name, err)
+			ok = false
+		}
+	}
+
+	if !ok {
+		log.Errorf("keeping staging area '%s' of repo '%s/%s'", t.staging, t.r.Owner, t.r.Name)
+		return
+	}
+
+	t.r.storage.RemoveAll(t.staging)
+}
+
+// finish runs the actions deferred until the txn is published and removes
+// the staging area. The repo is consistent even if it fails, but files
+// which should have been archived may be left in the arch dirs.
+func (t *txn) finish() error {
+	defer t.r.storage.RemoveAll(t.staging)
+
+	for _, done := range t.done {
+		err := done()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// commit publishes the changes of the txn. On failure the repo is left
+// unchanged.
+func (t *txn) commit() error {
+	return commit(t)
+}
+
+// commit publishes the changes of a set of txns on different repos. The
+// txns are published one after the other and if any of them fails the
+// already published txns are reverted, such that either all or none of
+// them are applied. nil txns are skipped.
+func commit(txns ...*txn) error {
+	var active []*txn
+	for _, t := range txns {
+		if t != nil {
+			active = append(active, t)
+		}
+	}
+
+	abort := func() {
+		for _, t := range active {
+			t.abort()
+		}
+	}
+
+	for _, t := range active {
+		err := t.prepare()
+		if err != nil {
+			abort()
+			return err
+		}
+	}
+
+	for _, t := range active {
+		err := t.publish()
+		if err != nil {
+			abort()
+			return err
+		}
+	}
+
+	var err error
+	for _, t := range active {
+		if ferr := t.finish(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,245 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingStorage is a local storage failing to rename files to fail. If
+// once is true only the first rename fails.
+type failingStorage struct {
+	LocalStorage
+	fail string
+	once bool
+}
+
+func (s *failingStorage) Rename(src, dst string) error {
+	if dst == s.fail {
+		if s.once {
+			s.fail = ""
+		}
+		return errors.New("rename failed")
+	}
+	return s.LocalStorage.Rename(src, dst)
+}
+
+// Test that a failed update leaves the repo unchanged.
+func TestTxnRollback(t *testing.T) {
+	st := &failingStorage{}
+	r := NewRepoStorage(&model.Repo{Name: "txn", Archs: []string{"x86_64", "aarch64"}, HistorySize: 2}, repoStorage, st)
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	err = r.Add([]string{writeTestPkg(t, r.UploadPath(), "foo", "1.0-1", "any")})
+	assert.NoError(t, err, "should not fail")
+
+	checkVersion := func(version string) {
+		for _, arch := range r.Archs {
+			pkg, err := r.Package("foo", arch, false)
+			assert.NoError(t, err, "should not fail")
+			assert.Equal(t, version, pkg.Version, "should be equal")
+
+			_, err = os.Stat(path.Join(r.PathDeep(arch), pkg.FileName))
+			assert.NoError(t, err, "should not fail")
+		}
+	}
+
+	// publishing the dbs of the last arch fails after the first arch has
+	// been published.
+	st.fail = r.DB("x86_64")
+
+	err = r.Add([]string{writeTestPkg(t, r.UploadPath(), "foo", "2.0-1", "any")})
+	assert.Error(t, err, "should fail")
+	checkVersion("1.0-1")
+
+	for _, arch := range r.Archs {
+		_, err = os.Stat(path.Join(r.PathDeep(arch), "foo-2.0-1-any.pkg.tar.xz"))
+		assert.True(t, os.IsNotExist(err), "should not exist")
+	}
+
+	_, err = os.Stat(path.Join(r.UploadPath(), "foo-2.0-1-any.pkg.tar.xz"))
+	assert.NoError(t, err, "should not fail")
+
+	err = r.Remove([]string{"foo"}, "x86_64")
+	assert.Error(t, err, "should fail")
+	checkVersion("1.0-1")
+
+	st.fail = ""
+
+	err = r.Add([]string{path.Join(r.UploadPath(), "foo-2.0-1-any.pkg.tar.xz")})
+	assert.NoError(t, err, "should not fail")
+	checkVersion("2.0-1")
+
+	versions, err := r.History("foo", "x86_64")
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, versions, 2, "should have 2 versions")
+
+	staging, err := st.List(path.Join(r.Path(), ".staging"))
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, staging, 0, "should be empty")
+}
+
+// Test that dbs and their signatures stay paired if publishing a signed repo
+// fails.
+func TestTxnRollbackSigned(t *testing.T) {
+	key, err := NewSigningKey("owner", "txnsigned")
+	assert.NoError(t, err, "should not fail")
+
+	st := &failingStorage{once: true}
+	r := NewRepoStorage(&model.Repo{Name: "txnsigned", Archs: []string{"x86_64", "aarch64"}, SigningKey: key}, repoStorage, st)
+	err = r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	pubKey, err := r.PublicKey()
+	assert.NoError(t, err, "should not fail")
+
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(pubKey))
+	assert.NoError(t, err, "should not fail")
+
+	err = r.Add([]string{writeTestPkg(t, r.UploadPath(), "foo", "1.0-1", "any")})
+	assert.NoError(t, err, "should not fail")
+
+	// the signature of the last db fails after its db has been
+	// replaced.
+	st.fail = r.DB("x86_64") + ".sig"
+
+	err = r.Add([]string{writeTestPkg(t, r.UploadPath(), "foo", "2.0-1", "any")})
+	assert.Error(t, err, "should fail")
+
+	for _, arch := range r.Archs {
+		pkg, err := r.Package("foo", arch, false)
+		assert.NoError(t, err, "should not fail")
+		assert.Equal(t, "1.0-1", pkg.Version, "should be equal")
+
+		checkSig(t, keyring, r.DB(arch))
+		checkSig(t, keyring, r.FilesDB(arch))
+	}
+}
+
+// Test that the staging area is kept if an aborted txn can't be undone.
+func TestTxnAbortKeepsStaging(t *testing.T) {
+	st := &failingStorage{}
+	r := NewRepoStorage(&model.Repo{Name: "txn-abort", Archs: []string{"x86_64", "aarch64"}}, repoStorage, st)
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	tx := r.begin()
+	err = tx.removeDir(r.PathDeep("aarch64"))
+	assert.NoError(t, err, "should not fail")
+
+	st.fail = r.PathDeep("aarch64")
+	tx.abort()
+
+	_, err = os.Stat(path.Join(tx.staging, "removed", "aarch64"))
+	assert.NoError(t, err, "should not fail")
+
+	st.fail = ""
+	tx.abort()
+
+	_, err = os.Stat(r.PathDeep("aarch64"))
+	assert.NoError(t, err, "should not fail")
+
+	_, err = os.Stat(tx.staging)
+	assert.True(t, os.IsNotExist(err), "should not exist")
+}
+
+// pairStorage checks after every change that the dbs of a repo are signed
+// and that a db is paired with the signature of another version for at most
+// a single change, i.e. while the db and its signature are swapped.
+type pairStorage struct {
+	LocalStorage
+	t        *testing.T
+	keyring  openpgp.EntityList
+	dbs      []string
+	mismatch map[string]bool
+}
+
+func (s *pairStorage) check() {
+	for _, db := range s.dbs {
+		if _, err := os.Stat(db); err != nil {
+			continue
+		}
+
+		if _, err := os.Stat(db + ".sig"); err != nil {
+			s.t.Errorf("db %s is unsigned", db)
+			continue
+		}
+
+		err := verifyPkgSignature(s.keyring, db)
+		if err != nil && s.mismatch[db] {
+			s.t.Errorf("db %s is paired with another signature: %s", db, err)
+		}
+		s.mismatch[db] = err != nil
+	}
+}
+
+func (s *pairStorage) Put(name string, r io.Reader) error {
+	defer s.check()
+	return s.LocalStorage.Put(name, r)
+}
+
+func (s *pairStorage) Copy(src, dst string) error {
+	defer s.check()
+	return s.LocalStorage.Copy(src, dst)
+}
+
+func (s *pairStorage) Rename(src, dst string) error {
+	defer s.check()
+	return s.LocalStorage.Rename(src, dst)
+}
+
+func (s *pairStorage) Link(target, name string) error {
+	defer s.check()
+	return s.LocalStorage.Link(target, name)
+}
+
+func (s *pairStorage) Remove(name string) error {
+	defer s.check()
+	return s.LocalStorage.Remove(name)
+}
+
+// Test that readers never see a db without its signature.
+func TestTxnPublishSigned(t *testing.T) {
+	key, err := NewSigningKey("owner", "txnpublish")
+	assert.NoError(t, err, "should not fail")
+
+	st := &pairStorage{t: t, mismatch: make(map[string]bool)}
+	r := NewRepoStorage(&model.Repo{Name: "txnpublish", Archs: []string{"x86_64", "aarch64"}, SigningKey: key}, repoStorage, st)
+	err = r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	entity, err := r.signingEntity()
+	assert.NoError(t, err, "should not fail")
+	st.keyring = openpgp.EntityList{entity}
+
+	for _, arch := range r.Archs {
+		for _, db := range []string{r.DB(arch), r.FilesDB(arch)} {
+			st.dbs = append(st.dbs, db, strings.TrimSuffix(db, ".tar.gz"))
+		}
+	}
+
+	for _, version := range []string{"1.0-1", "2.0-1"} {
+		err = r.Add([]string{writeTestPkg(t, r.UploadPath(), "foo", version, "any")})
+		assert.NoError(t, err, "should not fail")
+	}
+
+	err = r.Remove([]string{"foo"}, "x86_64")
+	assert.NoError(t, err, "should not fail")
+
+	for _, db := range st.dbs {
+		assert.False(t, st.mismatch[db], "should be paired")
+	}
+}
@@ -0,0 +1,355 @@
+package repo
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mikkeloscar/gopkgbuild"
+)
+
+// Kinds of problems found by Verify.
+const (
+	// ProblemMissingFile is a db entry without a package file.
+	ProblemMissingFile = "missing_file"
+	// ProblemChecksum is a package file not matching the size or sha256
+	// sum of its db entry.
+	ProblemChecksum = "checksum_mismatch"
+	// ProblemUntracked is a file in an arch dir which isn't in the db.
+	ProblemUntracked = "untracked_file"
+	// ProblemMissingAny is an 'any' package missing from an arch.
+	ProblemMissingAny = "missing_any"
+)
+
+// Problem describes an inconsistency between the dbs and the files of a
+// repo.
+type Problem struct {
+	Kind     string `json:"kind"`
+	Arch     string `json:"arch"`
+	Package  string `json:"package,omitempty"`
+	File     string `json:"file"`
+	Detail   string `json:"detail,omitempty"`
+	Repaired bool   `json:"repaired"`
+}
+
+func (p *Problem) String() string {
+	s := fmt.Sprintf("%s: %s: %s", p.Arch, p.Kind, p.File)
+	if p.Detail != "" {
+		s += " (" + p.Detail + ")"
+	}
+	if p.Repaired {
+		s += " [repaired]"
+	}
+	return s
+}
+
+// Verify checks that every db entry of the repo has a package file with
+// matching size and checksum, that every file in the arch dirs is in the db
+// and that 'any' packages are available in every arch. If repair is true
+// the problems are fixed:
+//
+//   - Entries without a package file are removed from the db.
+//   - Entries with a mismatching package file are recreated from the file,
+//     or removed together with the file if it's not a valid package.
+//   - Untracked package files are moved to the archive, other untracked
+//     files are removed.
+//   - Missing 'any' packages are copied from the arch with the newest
+//     version.
+//
+// The repairs of all archs are published at once.
+func (r *Repo) Verify(repair bool) ([]*Problem, error) {
+	lock := r.rlock
+	if repair {
+		lock = r.lock
+	}
+
+	unlock, err := lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// problems are only reported if there's no txn.
+	var t *txn
+	if repair {
+		t = r.begin()
+	}
+
+	var problems []*Problem
+
+	archEntries := make(map[string]map[string]*dbEntry, len(r.Archs))
+
+	for _, arch := range r.Archs {
+		entries, probs, err := r.verifyArch(t, arch)
+		if err != nil {
+			if repair {
+				t.abort()
+			}
+			return nil, err
+		}
+
+		archEntries[arch] = entries
+		problems = append(problems, probs...)
+	}
+
+	probs, err := r.verifyAny(t, archEntries)
+	if err != nil {
+		if repair {
+			t.abort()
+		}
+		return nil, err
+	}
+	problems = append(problems, probs...)
+
+	if repair && len(problems) > 0 {
+		err = t.commit()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return problems, nil
+}
+
+// verifyArch checks the db of an arch against the files in the arch dir. It
+// returns the db entries, repaired in the txn if there is one.
+func (r *Repo) verifyArch(t *txn, arch string) (map[string]*dbEntry, []*Problem, error) {
+	repair := t != nil
+
+	entries, err := readDB(r.storage, r.FilesDB(arch))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, err := r.storage.List(r.PathDeep(arch))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	onDisk := make(map[string]struct{}, len(files))
+	for _, f := range files {
+		if !f.IsDir {
+			onDisk[f.Name] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []*Problem
+	changed := false
+	tracked := make(map[string]struct{}, len(entries))
+
+	for _, name := range names {
+		entry := entries[name]
+		file := entry.pkg.FileName
+
+		if _, ok := onDisk[file]; !ok {
+			problems = append(problems, &Problem{
+				Kind:     ProblemMissingFile,
+				Arch:     arch,
+				Package:  name,
+				File:     file,
+				Repaired: repair,
+			})
+
+			if repair {
+				delete(entries, name)
+				changed = true
+			}
+			continue
+		}
+
+		tracked[file] = struct{}{}
+
+		pkgPath := path.Join(r.PathDeep(arch), file)
+
+		size, _, sha256sum, err := pkgChecksums(r.storage, pkgPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if strconv.FormatInt(size, 10) == entry.pkg.CSize && sha256sum == entry.pkg.SHA256Sum {
+			continue
+		}
+
+		problem := &Problem{
+			Kind:    ProblemChecksum,
+			Arch:    arch,
+			Package: name,
+			File:    file,
+			Detail: fmt.Sprintf("db: %s bytes, sha256 %s; file: %d bytes, sha256 %s",
+				entry.pkg.CSize, entry.pkg.SHA256Sum, size, sha256sum),
+			Repaired: repair,
+		}
+		problems = append(problems, problem)
+
+		if !repair {
+			continue
+		}
+
+		changed = true
+		delete(entries, name)
+
+		newEntry, err := newDBEntry(r.storage, pkgPath)
+		if err != nil {
+			problem.Detail += "; removed invalid package: " + err.Error()
+			t.removeFile(arch, file)
+			continue
+		}
+
+		entries[newEntry.pkg.Name] = newEntry
+	}
+
+	for _, f := range files {
+		if f.IsDir || r.isDBFile(f.Name) {
+			continue
+		}
+
+		if _, ok := tracked[f.Name]; ok {
+			continue
+		}
+
+		// signatures are handled together with their package file.
+		if strings.HasSuffix(f.Name, ".sig") {
+			if _, ok := onDisk[strings.TrimSuffix(f.Name, ".sig")]; ok {
+				continue
+			}
+		}
+
+		problems = append(problems, &Problem{
+			Kind:     ProblemUntracked,
+			Arch:     arch,
+			File:     f.Name,
+			Repaired: repair,
+		})
+
+		if !repair {
+			continue
+		}
+
+		_, _, _, err := splitFileNameVersion(f.Name)
+		if err == nil && !strings.HasSuffix(f.Name, ".sig") {
+			t.archive(arch, f.Name)
+		} else {
+			t.removeFile(arch, f.Name)
+		}
+	}
+
+	if changed {
+		t.dbs[arch] = entries
+	}
+
+	return entries, problems, nil
+}
+
+// isDBFile returns true if the file is one of the dbs, db links or db
+// signatures of the repo, including signatures being published.
+func (r *Repo) isDBFile(file string) bool {
+	file = strings.TrimSuffix(strings.TrimSuffix(file, ".new"), ".sig")
+
+	for _, db := range []string{".db", ".files"} {
+		if file == r.Name+db || file == r.Name+db+".tar.gz" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyAny checks that the newest version of every 'any' package is in the
+// db of every arch. An arch having a newer version of the package, e.g.
+// built for the specific arch, is fine. Missing packages are added in the
+// txn if there is one.
+func (r *Repo) verifyAny(t *txn, archEntries map[string]map[string]*dbEntry) ([]*Problem, error) {
+	repair := t != nil
+
+	type anyPkg struct {
+		arch    string
+		entry   *dbEntry
+		version *pkgbuild.CompleteVersion
+	}
+
+	newest := make(map[string]*anyPkg)
+
+	for _, arch := range r.Archs {
+		for name, entry := range archEntries[arch] {
+			if entry.pkg.Arch != "any" {
+				continue
+			}
+
+			version, err := pkgbuild.NewCompleteVersion(entry.pkg.Version)
+			if err != nil {
+				return nil, err
+			}
+
+			if n, ok := newest[name]; !ok || version.Newer(n.version) {
+				newest[name] = &anyPkg{arch, entry, version}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(newest))
+	for name := range newest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []*Problem
+
+	for _, arch := range r.Archs {
+		var added []*dbEntry
+
+		for _, name := range names {
+			pkg := newest[name]
+
+			if entry, ok := archEntries[arch][name]; ok {
+				version, err := pkgbuild.NewCompleteVersion(entry.pkg.Version)
+				if err != nil {
+					return nil, err
+				}
+
+				if !pkg.version.Newer(version) {
+					continue
+				}
+			}
+
+			problems = append(problems, &Problem{
+				Kind:     ProblemMissingAny,
+				Arch:     arch,
+				Package:  name,
+				File:     pkg.entry.pkg.FileName,
+				Detail:   "available in " + pkg.arch,
+				Repaired: repair,
+			})
+
+			if !repair {
+				continue
+			}
+
+			err := t.copyPkgFile(
+				path.Join(r.PathDeep(pkg.arch), pkg.entry.pkg.FileName),
+				path.Join(r.PathDeep(arch), pkg.entry.pkg.FileName),
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			added = append(added, pkg.entry)
+		}
+
+		if len(added) > 0 {
+			err := t.add(arch, added)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test verifying and repairing a repo.
+func TestVerify(t *testing.T) {
+	r := NewRepo(&model.Repo{Name: "verify", Archs: []string{"x86_64", "aarch64"}}, repoStorage)
+	err := r.InitDir()
+	assert.NoError(t, err, "should not fail")
+	defer r.ClearPath()
+
+	pkgs := []string{
+		writeTestPkg(t, r.Path(), "foo", "1.0-1", "x86_64"),
+		writeTestPkg(t, r.Path(), "bar", "1.0-1", "any"),
+		writeTestPkg(t, r.Path(), "baz", "1.0-1", "x86_64"),
+	}
+	err = r.Add(pkgs)
+	assert.NoError(t, err, "should not fail")
+
+	problems, err := r.Verify(false)
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, problems, 0, "should have no problems")
+
+	// package file removed behind the back of the db.
+	err = os.Remove(path.Join(r.PathDeep("x86_64"), "foo-1.0-1-x86_64.pkg.tar.xz"))
+	assert.NoError(t, err, "should not fail")
+
+	// package file replaced by a different build.
+	writeTestPkg(t, r.PathDeep("x86_64"), "baz", "1.0-1", "x86_64", "url = https://example.org")
+
+	// package file not in the db.
+	writeTestPkg(t, r.PathDeep("x86_64"), "qux", "1.0-1", "x86_64")
+
+	// 'any' package missing from an arch.
+	err = r.Remove([]string{"bar"}, "aarch64")
+	assert.NoError(t, err, "should not fail")
+
+	expected := []*Problem{
+		{Kind: ProblemChecksum, Arch: "x86_64", Package: "baz", File: "baz-1.0-1-x86_64.pkg.tar.xz"},
+		{Kind: ProblemMissingFile, Arch: "x86_64", Package: "foo", File: "foo-1.0-1-x86_64.pkg.tar.xz"},
+		{Kind: ProblemUntracked, Arch: "x86_64", File: "qux-1.0-1-x86_64.pkg.tar.xz"},
+		{Kind: ProblemMissingAny, Arch: "aarch64", Package: "bar", File: "bar-1.0-1-any.pkg.tar.xz"},
+	}
+
+	check := func(problems []*Problem, repaired bool) {
+		assert.Len(t, problems, len(expected), "should be equal")
+		if len(problems) != len(expected) {
+			return
+		}
+
+		for i, problem := range problems {
+			assert.Equal(t, expected[i].Kind, problem.Kind, "should be equal")
+			assert.Equal(t, expected[i].Arch, problem.Arch, "should be equal")
+			assert.Equal(t, expected[i].Package, problem.Package, "should be equal")
+			assert.Equal(t, expected[i].File, problem.File, "should be equal")
+			assert.Equal(t, repaired, problem.Repaired, "should be equal")
+		}
+	}
+
+	problems, err = r.Verify(false)
+	assert.NoError(t, err, "should not fail")
+	check(problems, false)
+
+	problems, err = r.Verify(true)
+	assert.NoError(t, err, "should not fail")
+	check(problems, true)
+
+	problems, err = r.Verify(false)
+	assert.NoError(t, err, "should not fail")
+	assert.Len(t, problems, 0, "should have no problems")
+
+	pkg, err := r.Package("foo", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.Nil(t, pkg, "should be nil")
+
+	pkg, err = r.Package("baz", "x86_64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, "https://example.org", pkg.URL, "should be equal")
+
+	pkg, err = r.Package("bar", "aarch64", false)
+	assert.NoError(t, err, "should not fail")
+	assert.NotNil(t, pkg, "should not be nil")
+
+	_, err = os.Stat(path.Join(r.PathDeep("x86_64"), "qux-1.0-1-x86_64.pkg.tar.xz"))
+	assert.True(t, os.IsNotExist(err), "should be true")
+}
@@ -52,32 +52,39 @@ func RepoPerm(c *gin.Context) *model.Perm {
 	return u
 }
 
+// Perm returns the permissions of a user for a repo. user may be nil for
+// anonymous access.
+func Perm(user *model.User, repo *model.Repo) *model.Perm {
+	perm := &model.Perm{}
+
+	switch {
+	case user != nil && user.Admin:
+		perm.Read = true
+		perm.Write = true
+		perm.Admin = true
+	case user != nil && user.ID == repo.UserID:
+		perm.Read = true
+		perm.Write = true
+		perm.Admin = true
+	default:
+		if !repo.Private {
+			perm.Read = true
+		} else {
+			perm.Read = false
+		}
+		perm.Write = false
+		perm.Admin = false
+	}
+
+	return perm
+}
+
 func SetRepoPerm() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := User(c)
 		repo := Repo(c)
-		perm := &model.Perm{}
 
-		switch {
-		case user != nil && user.Admin:
-			perm.Read = true
-			perm.Write = true
-			perm.Admin = true
-		case user != nil && user.ID == repo.UserID:
-			perm.Read = true
-			perm.Write = true
-			perm.Admin = true
-		default:
-			if !repo.Private {
-				perm.Read = true
-			} else {
-				perm.Read = false
-			}
-			perm.Write = false
-			perm.Admin = false
-		}
-
-		c.Set("perm", perm)
+		c.Set("perm", Perm(user, repo.Repo))
 		c.Next()
 	}
 }
@@ -100,6 +107,24 @@ func RepoWrite() gin.HandlerFunc {
 	}
 }
 
+func RepoAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		perm := RepoPerm(c)
+		repo := Repo(c)
+		status := http.StatusUnauthorized
+		if repo.Private {
+			// don't leak info if private
+			status = http.StatusNotFound
+		}
+
+		if perm != nil && perm.Admin {
+			c.Next()
+		} else {
+			c.AbortWithStatus(status)
+		}
+	}
+}
+
 func RepoRead() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		perm := RepoPerm(c)
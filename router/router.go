@@ -21,6 +21,8 @@ func Load(middleware ...gin.HandlerFunc) http.Handler {
 		repo.Use(session.SetRepo())
 		repo.Use(session.SetRepoPerm())
 		repo.Use(session.RepoRead())
+		repo.GET("/key", controller.ServeRepoKey)
+		repo.GET("/snapshots/:snapshot/:arch/:file", controller.ServeSnapshotFile)
 		repo.GET("/:arch/:file", controller.ServeRepoFile)
 	}
 
@@ -39,24 +41,70 @@ func Load(middleware ...gin.HandlerFunc) http.Handler {
 			repo.PATCH("", session.RepoWrite(), controller.PatchRepo)
 			repo.DELETE("", session.RepoWrite(), controller.DeleteRepo)
 
+			snapshots := repo.Group("/snapshots")
+			{
+				snapshots.GET("", controller.GetSnapshots)
+				snapshots.POST("", session.RepoWrite(), controller.PostSnapshot)
+				snapshots.DELETE("/:snapshot", session.RepoWrite(), controller.DeleteSnapshot)
+			}
+
+			repo.GET("/obsolete", session.RepoWrite(), controller.GetObsolete)
+			repo.POST("/obsolete", session.RepoAdmin(), controller.PostObsolete)
+
+			repo.GET("/verify", session.RepoAdmin(), controller.GetVerify)
+			repo.POST("/verify", session.RepoAdmin(), controller.PostVerify)
+			repo.POST("/rebuild", session.RepoAdmin(), controller.PostRebuild)
+
+			repo.POST("/promote", session.IsUser(), controller.PostPromote)
+			repo.GET("/promotions", controller.GetPromotions)
+
+			keys := repo.Group("/keys")
+			{
+				keys.GET("", controller.GetRepoKeys)
+				keys.POST("", session.RepoWrite(), controller.PostRepoKey)
+				keys.DELETE("/:keyid", session.RepoWrite(), controller.DeleteRepoKey)
+			}
+
+			// per arch view which can't be placed below /:arch
+			// without shadowing packages of the same name.
+			repo.GET("/graph/:arch", controller.GetRepoGraph)
+
 			packages := repo.Group("/:arch")
 			{
 				packages.GET("", controller.GetRepoPackages)
+				// the static groups segment takes precedence over
+				// a package named groups.
+				packages.GET("/groups", controller.GetRepoGroups)
+				packages.GET("/groups/:group", controller.GetRepoGroup)
 				packages.GET("/:package", controller.GetRepoPackage)
 				packages.DELETE("/:package", session.RepoWrite(), controller.DeleteRepoPackage)
 				packages.GET("/:package/files", controller.GetRepoPackageFiles)
+				packages.GET("/:package/deps", controller.GetRepoPackageDeps)
+				packages.GET("/:package/rdeps", controller.GetRepoPackageReverseDeps)
+				packages.GET("/:package/history", controller.GetRepoPackageHistory)
+				packages.POST("/:package/rollback", session.RepoWrite(), controller.PostRepoPackageRollback)
 			}
 
+			repo.POST("/packages", session.RepoWrite(), controller.PostRepoPackages)
+
 			upload := repo.Group("/upload")
 			{
 				upload.Use(session.RepoWrite())
 				upload.POST("/start", controller.PostUploadStart)
 				upload.POST("/file/:filename/:sessionid", controller.PostUploadFile)
+				upload.OPTIONS("/files/:sessionid", controller.OptionsResumableUpload)
+				upload.POST("/files/:sessionid", controller.PostResumableUpload)
+				upload.HEAD("/files/:sessionid/:filename", controller.HeadResumableUpload)
+				upload.PATCH("/files/:sessionid/:filename", controller.PatchResumableUpload)
 				upload.POST("/done/:sessionid", controller.PostUploadDone)
+				upload.DELETE("/:sessionid", controller.DeleteUpload)
 			}
 		}
 	}
 
+	e.GET("/api/files", controller.GetFiles)
+	e.GET("/api/search", controller.GetSearch)
+
 	user := e.Group("/api/user")
 	{
 		user.Use(session.IsUser())
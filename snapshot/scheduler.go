@@ -0,0 +1,66 @@
+package snapshot
+
+import (
+	"time"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// Scheduler creates daily snapshots of repos with automatic snapshots
+// enabled.
+type Scheduler struct {
+	Store store.Store
+}
+
+// snapshot creates today's snapshot of a repo, if it doesn't exist already,
+// and prunes old snapshots based on the retention of the repo.
+func (s *Scheduler) snapshot(r *model.Repo, now time.Time) error {
+	fsRepo := repo.NewRepo(r, repo.RepoStorage)
+	name := now.Format(repo.SnapshotDateFormat)
+
+	_, err := fsRepo.CreateSnapshot(name)
+	switch err {
+	case nil:
+		log.Printf("Created snapshot '%s' of repo '%s/%s'", name, r.Owner, r.Name)
+	case repo.ErrSnapshotExists:
+		// already snapshotted today.
+	default:
+		return err
+	}
+
+	if r.SnapshotRetention > 0 {
+		return fsRepo.PruneSnapshots(r.SnapshotRetention)
+	}
+
+	return nil
+}
+
+// Run runs the scheduler creating daily snapshots.
+func (s *Scheduler) Run() {
+	for {
+		select {
+		case <-time.After(time.Hour):
+			repos, err := s.Store.Repos().GetRepoList()
+			if err != nil {
+				log.Errorf("failed to fetch repos from db: %s", err)
+				break
+			}
+
+			now := time.Now().UTC()
+
+			for _, r := range repos {
+				if !r.SnapshotDaily {
+					continue
+				}
+
+				err := s.snapshot(r, now)
+				if err != nil {
+					log.Errorf("failed to snapshot repo '%s/%s': %s", r.Owner, r.Name, err)
+				}
+			}
+		}
+	}
+}
@@ -5,8 +5,24 @@ import (
 	"github.com/mikkeloscar/gopkgbuild"
 	"github.com/mikkeloscar/maze/common/util"
 	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/source"
 )
 
+// Name is the packages.yml section listing AUR packages.
+const Name = "aur"
+
+// Source checks packages in the AUR for updates.
+type Source struct{}
+
+func init() {
+	source.Register(Name, Source{})
+}
+
+// Updates implements source.Source.
+func (Source) Updates(pkgs []string, repo *repo.Repo, _ source.Files) ([][]string, [][]string, error) {
+	return Updates(pkgs, repo)
+}
+
 // Updates check for updated packages based on a list of packages and a
 // repository. Returns a list of packages with updates.
 func Updates(pkgs []string, repo *repo.Repo) ([][]string, [][]string, error) {
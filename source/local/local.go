@@ -0,0 +1,112 @@
+package local
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/mikkeloscar/gopkgbuild"
+	"github.com/mikkeloscar/maze/common/util"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/source"
+)
+
+// Name is the packages.yml section listing directories of the source repo
+// holding a PKGBUILD.
+const Name = "local"
+
+// Source checks PKGBUILDs in the source repo for updates.
+type Source struct{}
+
+func init() {
+	source.Register(Name, Source{})
+}
+
+// Updates implements source.Source. The .SRCINFO of each directory is
+// compared to the repository. The packages of a PKGBUILD are built together
+// and therefore returned as one group.
+func (Source) Updates(dirs []string, repo *repo.Repo, files source.Files) ([][]string, [][]string, error) {
+	var updates [][]string
+	var checks [][]string
+
+	for _, dir := range dirs {
+		pkgb, err := readSRCINFO(dir, files)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		archs := pkgArchs(pkgb, repo)
+		if len(archs) == 0 {
+			continue
+		}
+
+		new := false
+		for _, name := range pkgb.Pkgnames {
+			for _, arch := range archs {
+				n, err := repo.IsNew(name, arch, pkgb.CompleteVersion())
+				if err != nil {
+					return nil, nil, err
+				}
+
+				new = new || n
+			}
+		}
+
+		if new {
+			updates = append(updates, pkgb.Pkgnames)
+		} else if pkgb.IsDevel() {
+			checks = append(checks, pkgb.Pkgnames)
+		}
+	}
+
+	return updates, checks, nil
+}
+
+// Packages implements source.Lister. The names of the packages are read
+// from the .SRCINFO of each directory.
+func (Source) Packages(dirs []string, files source.Files) ([]string, error) {
+	var pkgs []string
+
+	for _, dir := range dirs {
+		pkgb, err := readSRCINFO(dir, files)
+		if err != nil {
+			return nil, err
+		}
+
+		pkgs = append(pkgs, pkgb.Pkgnames...)
+	}
+
+	return pkgs, nil
+}
+
+// readSRCINFO reads and parses the .SRCINFO of a directory.
+func readSRCINFO(dir string, files source.Files) (*pkgbuild.PKGBUILD, error) {
+	srcinfo := path.Join(dir, ".SRCINFO")
+
+	content, err := files.ReadFile(srcinfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", srcinfo, err)
+	}
+
+	pkgb, err := pkgbuild.ParseSRCINFOContent(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", srcinfo, err)
+	}
+
+	return pkgb, nil
+}
+
+// pkgArchs returns the archs of the repository the PKGBUILD is built for.
+func pkgArchs(pkgb *pkgbuild.PKGBUILD, repo *repo.Repo) []string {
+	if util.StrContains("any", pkgb.Arch) {
+		return []string{"any"}
+	}
+
+	var archs []string
+	for _, arch := range pkgb.Arch {
+		if util.StrContains(arch, repo.Archs) {
+			archs = append(archs, arch)
+		}
+	}
+
+	return archs
+}
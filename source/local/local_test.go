@@ -0,0 +1,72 @@
+package local
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+type files map[string]string
+
+func (f files) ReadFile(path string) ([]byte, error) {
+	content, ok := f[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(content), nil
+}
+
+const srcinfoFoo = `pkgbase = foo
+	pkgver = 1.0
+	pkgrel = 1
+	arch = x86_64
+
+pkgname = foo
+
+pkgname = foo-docs
+`
+
+const srcinfoBar = `pkgbase = bar
+	pkgver = 1.0
+	pkgrel = 1
+	arch = armv7h
+
+pkgname = bar
+`
+
+func TestUpdates(t *testing.T) {
+	dir, err := os.MkdirTemp("", "maze-local")
+	assert.NoError(t, err, "should not fail")
+	defer os.RemoveAll(dir)
+
+	r := repo.NewRepo(&model.Repo{Name: "local", Archs: []string{"x86_64"}}, dir)
+	err = r.InitDir()
+	assert.NoError(t, err, "should not fail")
+
+	src := files{
+		"pkgs/foo/.SRCINFO": srcinfoFoo,
+		"pkgs/bar/.SRCINFO": srcinfoBar,
+	}
+
+	updates, checks, err := Source{}.Updates([]string{"pkgs/foo", "pkgs/bar"}, r, src)
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, [][]string{{"foo", "foo-docs"}}, updates, "should be equal")
+	assert.Len(t, checks, 0, "should be empty")
+
+	_, _, err = Source{}.Updates([]string{"pkgs/baz"}, r, src)
+	assert.Error(t, err, "should fail")
+}
+
+func TestPackages(t *testing.T) {
+	src := files{
+		"pkgs/foo/.SRCINFO": srcinfoFoo,
+		"pkgs/bar/.SRCINFO": srcinfoBar,
+	}
+
+	pkgs, err := Source{}.Packages([]string{"pkgs/foo", "pkgs/bar"}, src)
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, []string{"foo", "foo-docs", "bar"}, pkgs, "should be equal")
+}
@@ -1,11 +1,43 @@
 package source
 
-import "github.com/mikkeloscar/maze/repo"
+import (
+	"sync"
 
+	"github.com/mikkeloscar/maze/repo"
+)
+
+// Source is a source of packages, e.g. the AUR. Each source is configured by
+// the section of packages.yml named after it.
 type Source interface {
-	// Updates takes a list of packagenames and returns a list of packages
-	// where a new version is available based on the provided respository.
-	Updates([]string, *repo.Repo) ([]string, error)
+	// Updates takes the entries of the packages.yml section of the source
+	// and returns groups of packages where a new version is available
+	// based on the provided repository, and groups of devel packages
+	// which should be checked for updates by building them. Files of the
+	// source repo, i.e. the repo holding packages.yml, are read from
+	// files.
+	Updates([]string, *repo.Repo, Files) ([][]string, [][]string, error)
+}
+
+// Files reads files from the source repo of a repository.
+type Files interface {
+	ReadFile(path string) ([]byte, error)
+}
+
+// Lister is implemented by sources whose packages.yml entries aren't the
+// names of the packages, e.g. directories holding a PKGBUILD.
+type Lister interface {
+	// Packages returns the names of the packages built from the entries.
+	Packages([]string, Files) ([]string, error)
+}
+
+// Packages returns the names of the packages wanted by the entries of the
+// packages.yml section of a source.
+func Packages(src Source, entries []string, files Files) ([]string, error) {
+	if l, ok := src.(Lister); ok {
+		return l.Packages(entries, files)
+	}
+
+	return entries, nil
 }
 
 type Pkg struct {
@@ -14,11 +46,29 @@ type Pkg struct {
 	Version string
 }
 
-// func Updates(typ string, pkgs []string, repo *repo.Repo) ([]string, error) {
-// 	switch typ {
-// 	case "aur":
-// 		return aur.Updates(pkgs, repo)
-// 	}
+var registry = struct {
+	sync.RWMutex
+	sources map[string]Source
+}{sources: make(map[string]Source)}
+
+// Register registers a source for a packages.yml section. Sources usually
+// register themselves when their package is imported.
+func Register(name string, src Source) {
+	registry.Lock()
+	defer registry.Unlock()
 
-// 	return nil, fmt.Errorf("invalid source type")
-// }
+	if _, ok := registry.sources[name]; ok {
+		panic("source: Register called twice for source " + name)
+	}
+
+	registry.sources[name] = src
+}
+
+// Get returns the source registered for a packages.yml section.
+func Get(name string) (Source, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	src, ok := registry.sources[name]
+	return src, ok
+}
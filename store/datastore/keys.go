@@ -0,0 +1,60 @@
+package datastore
+
+import (
+	"database/sql"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/russross/meddler"
+)
+
+type keyStore struct {
+	*sql.DB
+}
+
+func (db *keyStore) GetRepoKeys(repo *model.Repo) ([]*model.Key, error) {
+	var keys []*model.Key
+	err := meddler.QueryAll(db, &keys, keyRepoQuery, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (db *keyStore) GetByKeyID(repo *model.Repo, keyID string) (*model.Key, error) {
+	key := new(model.Key)
+	err := meddler.QueryRow(db, key, keyIDQuery, repo.ID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (db *keyStore) Create(key *model.Key) error {
+	return meddler.Insert(db, keyTable, key)
+}
+
+func (db *keyStore) Delete(key *model.Key) error {
+	_, err := db.Exec(keyDeleteQuery, key.ID)
+	return err
+}
+
+const keyTable = "repo_keys"
+
+const keyRepoQuery = `
+SELECT *
+FROM repo_keys
+WHERE repo_id = ?
+ORDER BY id
+`
+
+const keyIDQuery = `
+SELECT *
+FROM repo_keys
+WHERE repo_id = ? AND key_id = ?
+LIMIT 1
+`
+
+const keyDeleteQuery = `
+DELETE FROM repo_keys
+WHERE id = ?
+`
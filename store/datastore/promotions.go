@@ -0,0 +1,34 @@
+package datastore
+
+import (
+	"database/sql"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/russross/meddler"
+)
+
+type promotionStore struct {
+	*sql.DB
+}
+
+func (db *promotionStore) GetRepoPromotions(repo *model.Repo) ([]*model.Promotion, error) {
+	var promotions []*model.Promotion
+	err := meddler.QueryAll(db, &promotions, promotionRepoQuery, repo.ID, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+func (db *promotionStore) Create(promotion *model.Promotion) error {
+	return meddler.Insert(db, promotionTable, promotion)
+}
+
+const promotionTable = "promotions"
+
+const promotionRepoQuery = `
+SELECT *
+FROM promotions
+WHERE source_repo_id = ? OR target_repo_id = ?
+ORDER BY created DESC, id DESC
+`
@@ -31,6 +31,9 @@ func New(driver, config string) (store.Store, error) {
 		driver,
 		&userStore{db},
 		&repoStore{db},
+		&keyStore{db},
+		&promotionStore{db},
+		&uploadStore{db},
 	), nil
 }
 
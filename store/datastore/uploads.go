@@ -0,0 +1,143 @@
+package datastore
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mikkeloscar/maze/model"
+	"github.com/russross/meddler"
+)
+
+type uploadStore struct {
+	*sql.DB
+}
+
+func (db *uploadStore) Get(sessionID string) (*model.UploadSession, error) {
+	session := new(model.UploadSession)
+	err := meddler.QueryRow(db, session, uploadSessionQuery, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (db *uploadStore) GetExpired(t time.Time) ([]*model.UploadSession, error) {
+	var sessions []*model.UploadSession
+	err := meddler.QueryAll(db, &sessions, uploadExpiredQuery, t.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (db *uploadStore) Create(session *model.UploadSession) error {
+	return meddler.Insert(db, uploadSessionTable, session)
+}
+
+func (db *uploadStore) Update(session *model.UploadSession) error {
+	return meddler.Update(db, uploadSessionTable, session)
+}
+
+func (db *uploadStore) Delete(session *model.UploadSession) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(uploadFilesDeleteQuery, session.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(uploadSessionDeleteQuery, session.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *uploadStore) GetFiles(session *model.UploadSession) ([]*model.UploadFile, error) {
+	var files []*model.UploadFile
+	err := meddler.QueryAll(db, &files, uploadFilesQuery, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (db *uploadStore) GetFile(session *model.UploadSession, path string) (*model.UploadFile, error) {
+	file := new(model.UploadFile)
+	err := meddler.QueryRow(db, file, uploadFileQuery, session.ID, path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (db *uploadStore) AddFile(file *model.UploadFile) error {
+	_, err := db.Exec(uploadFileDeleteQuery, file.SessionID, file.Path)
+	if err != nil {
+		return err
+	}
+	return meddler.Insert(db, uploadFileTable, file)
+}
+
+func (db *uploadStore) UpdateFile(file *model.UploadFile) error {
+	return meddler.Update(db, uploadFileTable, file)
+}
+
+func (db *uploadStore) DeleteFile(file *model.UploadFile) error {
+	_, err := db.Exec(uploadFileDeleteQuery, file.SessionID, file.Path)
+	return err
+}
+
+const (
+	uploadSessionTable = "upload_sessions"
+	uploadFileTable    = "upload_files"
+)
+
+const uploadSessionQuery = `
+SELECT *
+FROM upload_sessions
+WHERE session_id = ?
+LIMIT 1
+`
+
+const uploadExpiredQuery = `
+SELECT *
+FROM upload_sessions
+WHERE expires < ?
+ORDER BY id
+`
+
+const uploadSessionDeleteQuery = `
+DELETE FROM upload_sessions
+WHERE id = ?
+`
+
+const uploadFilesQuery = `
+SELECT *
+FROM upload_files
+WHERE session_id = ?
+ORDER BY id
+`
+
+const uploadFileQuery = `
+SELECT *
+FROM upload_files
+WHERE session_id = ? AND path = ?
+LIMIT 1
+`
+
+const uploadFileDeleteQuery = `
+DELETE FROM upload_files
+WHERE session_id = ? AND path = ?
+`
+
+const uploadFilesDeleteQuery = `
+DELETE FROM upload_files
+WHERE session_id = ?
+`
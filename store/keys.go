@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+
+	"github.com/mikkeloscar/maze/model"
+)
+
+type KeyStore interface {
+	// GetRepoKeys gets the trusted packager keys of a repo.
+	GetRepoKeys(*model.Repo) ([]*model.Key, error)
+
+	// GetByKeyID gets a trusted key of a repo by key ID.
+	GetByKeyID(*model.Repo, string) (*model.Key, error)
+
+	// Create adds a trusted key to a repo.
+	Create(*model.Key) error
+
+	// Delete deletes a trusted key.
+	Delete(*model.Key) error
+}
+
+func GetRepoKeys(c context.Context, repo *model.Repo) ([]*model.Key, error) {
+	return FromContext(c).Keys().GetRepoKeys(repo)
+}
+
+func GetKeyByKeyID(c context.Context, repo *model.Repo, keyID string) (*model.Key, error) {
+	return FromContext(c).Keys().GetByKeyID(repo, keyID)
+}
+
+func CreateKey(c context.Context, key *model.Key) error {
+	return FromContext(c).Keys().Create(key)
+}
+
+func DeleteKey(c context.Context, key *model.Key) error {
+	return FromContext(c).Keys().Delete(key)
+}
@@ -0,0 +1,5 @@
+-- +migrate Up
+
+ALTER TABLE upload_files ADD COLUMN size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE upload_files ADD COLUMN sha256 TEXT NOT NULL DEFAULT '';
+ALTER TABLE upload_files ADD COLUMN complete BOOLEAN NOT NULL DEFAULT 1;
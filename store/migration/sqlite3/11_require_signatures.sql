@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE repos ADD COLUMN require_signatures BOOLEAN NOT NULL DEFAULT 0;
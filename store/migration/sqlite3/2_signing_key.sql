@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE repos ADD COLUMN signing_key TEXT NOT NULL DEFAULT '';
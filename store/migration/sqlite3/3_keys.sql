@@ -0,0 +1,12 @@
+-- +migrate Up
+
+CREATE TABLE repo_keys (
+ id          INTEGER PRIMARY KEY AUTOINCREMENT
+,repo_id     INTEGER
+,key_id      TEXT
+,fingerprint TEXT
+,identity    TEXT
+,armored     TEXT
+
+,UNIQUE(repo_id, fingerprint)
+);
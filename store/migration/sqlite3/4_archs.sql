@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE repos ADD COLUMN archs TEXT NOT NULL DEFAULT '["x86_64"]';
@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE repos ADD COLUMN history_size INTEGER NOT NULL DEFAULT 3;
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+ALTER TABLE repos ADD COLUMN snapshot_daily BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE repos ADD COLUMN snapshot_retention INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+CREATE TABLE promotions (
+ id             INTEGER PRIMARY KEY AUTOINCREMENT
+,source_repo_id INTEGER
+,target_repo_id INTEGER
+,source         TEXT
+,target         TEXT
+,package        TEXT
+,version        TEXT
+,move           BOOLEAN
+,user_id        INTEGER
+,user           TEXT
+,created        DATETIME
+);
+
+CREATE INDEX ix_promotions_source ON promotions (source_repo_id);
+CREATE INDEX ix_promotions_target ON promotions (target_repo_id);
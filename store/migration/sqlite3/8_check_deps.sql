@@ -0,0 +1,4 @@
+-- +migrate Up
+
+ALTER TABLE repos ADD COLUMN check_deps BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE repos ADD COLUMN linked_repos TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,24 @@
+-- +migrate Up
+
+CREATE TABLE upload_sessions (
+ id         INTEGER PRIMARY KEY AUTOINCREMENT
+,session_id TEXT
+,repo_id    INTEGER
+,user_id    INTEGER
+,created    DATETIME
+,expires    DATETIME
+
+,UNIQUE(session_id)
+);
+
+CREATE INDEX ix_upload_sessions_expires ON upload_sessions (expires);
+
+CREATE TABLE upload_files (
+ id         INTEGER PRIMARY KEY AUTOINCREMENT
+,session_id INTEGER
+,path       TEXT
+,package    TEXT
+,created    DATETIME
+
+,UNIQUE(session_id, path)
+);
@@ -0,0 +1,24 @@
+package store
+
+import (
+	"context"
+
+	"github.com/mikkeloscar/maze/model"
+)
+
+type PromotionStore interface {
+	// GetRepoPromotions gets all promotions from or to a repo, newest
+	// first.
+	GetRepoPromotions(*model.Repo) ([]*model.Promotion, error)
+
+	// Create records a new promotion.
+	Create(*model.Promotion) error
+}
+
+func GetRepoPromotions(c context.Context, repo *model.Repo) ([]*model.Promotion, error) {
+	return FromContext(c).Promotions().GetRepoPromotions(repo)
+}
+
+func CreatePromotion(c context.Context, promotion *model.Promotion) error {
+	return FromContext(c).Promotions().Create(promotion)
+}
@@ -34,6 +34,10 @@ func GetRepoByOwnerName(c context.Context, owner, name string) (*model.Repo, err
 	return FromContext(c).Repos().GetByName(owner, name)
 }
 
+func GetRepoList(c context.Context) ([]*model.Repo, error) {
+	return FromContext(c).Repos().GetRepoList()
+}
+
 func CreateRepo(c context.Context, repo *model.Repo) error {
 	return FromContext(c).Repos().Create(repo)
 }
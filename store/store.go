@@ -3,12 +3,18 @@ package store
 type Store interface {
 	Users() UserStore
 	Repos() RepoStore
+	Keys() KeyStore
+	Promotions() PromotionStore
+	Uploads() UploadStore
 }
 
 type store struct {
-	name  string
-	users UserStore
-	repos RepoStore
+	name       string
+	users      UserStore
+	repos      RepoStore
+	keys       KeyStore
+	promotions PromotionStore
+	uploads    UploadStore
 }
 
 func (s *store) Users() UserStore {
@@ -19,10 +25,25 @@ func (s *store) Repos() RepoStore {
 	return s.repos
 }
 
-func New(name string, users UserStore, repos RepoStore) Store {
+func (s *store) Keys() KeyStore {
+	return s.keys
+}
+
+func (s *store) Promotions() PromotionStore {
+	return s.promotions
+}
+
+func (s *store) Uploads() UploadStore {
+	return s.uploads
+}
+
+func New(name string, users UserStore, repos RepoStore, keys KeyStore, promotions PromotionStore, uploads UploadStore) Store {
 	return &store{
 		name,
 		users,
 		repos,
+		keys,
+		promotions,
+		uploads,
 	}
 }
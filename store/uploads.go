@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/mikkeloscar/maze/model"
+)
+
+type UploadStore interface {
+	// Get gets an upload session by session ID.
+	Get(string) (*model.UploadSession, error)
+
+	// GetExpired gets all upload sessions which expired before the time.
+	GetExpired(time.Time) ([]*model.UploadSession, error)
+
+	// Create creates a new upload session.
+	Create(*model.UploadSession) error
+
+	// Update updates an upload session.
+	Update(*model.UploadSession) error
+
+	// Delete deletes an upload session and its files.
+	Delete(*model.UploadSession) error
+
+	// GetFiles gets the files of an upload session in upload order.
+	GetFiles(*model.UploadSession) ([]*model.UploadFile, error)
+
+	// GetFile gets a file of an upload session by path.
+	GetFile(*model.UploadSession, string) (*model.UploadFile, error)
+
+	// AddFile adds a file to an upload session. An existing file with the
+	// same path is replaced.
+	AddFile(*model.UploadFile) error
+
+	// UpdateFile updates a file of an upload session.
+	UpdateFile(*model.UploadFile) error
+
+	// DeleteFile deletes a file of an upload session.
+	DeleteFile(*model.UploadFile) error
+}
+
+func GetUploadSession(c context.Context, sessionID string) (*model.UploadSession, error) {
+	return FromContext(c).Uploads().Get(sessionID)
+}
+
+func CreateUploadSession(c context.Context, session *model.UploadSession) error {
+	return FromContext(c).Uploads().Create(session)
+}
+
+func UpdateUploadSession(c context.Context, session *model.UploadSession) error {
+	return FromContext(c).Uploads().Update(session)
+}
+
+func DeleteUploadSession(c context.Context, session *model.UploadSession) error {
+	return FromContext(c).Uploads().Delete(session)
+}
+
+func GetUploadFiles(c context.Context, session *model.UploadSession) ([]*model.UploadFile, error) {
+	return FromContext(c).Uploads().GetFiles(session)
+}
+
+func GetUploadFile(c context.Context, session *model.UploadSession, path string) (*model.UploadFile, error) {
+	return FromContext(c).Uploads().GetFile(session, path)
+}
+
+func AddUploadFile(c context.Context, file *model.UploadFile) error {
+	return FromContext(c).Uploads().AddFile(file)
+}
+
+func UpdateUploadFile(c context.Context, file *model.UploadFile) error {
+	return FromContext(c).Uploads().UpdateFile(file)
+}
+
+func DeleteUploadFile(c context.Context, file *model.UploadFile) error {
+	return FromContext(c).Uploads().DeleteFile(file)
+}
@@ -0,0 +1,70 @@
+package upload
+
+import (
+	"database/sql"
+	"os"
+	"time"
+
+	"github.com/mikkeloscar/maze/repo"
+	"github.com/mikkeloscar/maze/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// SessionTTL is how long an upload session is kept after its last activity.
+const SessionTTL = 6 * time.Hour
+
+// janitorInterval is the interval between checks for expired sessions.
+const janitorInterval = 15 * time.Minute
+
+// Janitor removes expired upload sessions along with their files, including
+// partially uploaded files.
+type Janitor struct {
+	Store store.Store
+}
+
+// clean removes the sessions which expired before now.
+func (j *Janitor) clean(now time.Time) error {
+	sessions, err := j.Store.Uploads().GetExpired(now)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sessions {
+		r, err := j.Store.Repos().Get(s.RepoID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		// the files of deleted repos are already gone.
+		if err == nil {
+			dir := repo.NewRepo(r, repo.RepoStorage).UploadSessionPath(s.SessionID)
+			err = os.RemoveAll(dir)
+			if err != nil {
+				log.Errorf("failed to remove upload session dir %s: %s", dir, err)
+				continue
+			}
+		}
+
+		err = j.Store.Uploads().Delete(s)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Removed expired upload session %s", s.SessionID)
+	}
+
+	return nil
+}
+
+// Run runs the janitor removing expired upload sessions.
+func (j *Janitor) Run() {
+	for {
+		select {
+		case <-time.After(janitorInterval):
+			err := j.clean(time.Now())
+			if err != nil {
+				log.Errorf("failed to clean upload sessions: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrOffsetMismatch is returned when a chunk doesn't start at the
+	// current offset of an upload.
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrChecksumMismatch is returned when the checksum of a chunk or of
+	// the complete file doesn't match the expected checksum.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrTooLarge is returned when a chunk exceeds the declared length of
+	// an upload.
+	ErrTooLarge = errors.New("chunk exceeds upload length")
+)
+
+// locks serializes writes to the same partial file.
+var locks = struct {
+	sync.Mutex
+	m map[string]*lockEntry
+}{m: make(map[string]*lockEntry)}
+
+type lockEntry struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock locks a file for writing and returns a function for unlocking it.
+func Lock(file string) func() {
+	locks.Lock()
+	l, ok := locks.m[file]
+	if !ok {
+		l = &lockEntry{}
+		locks.m[file] = l
+	}
+	l.refs++
+	locks.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		locks.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(locks.m, file)
+		}
+		locks.Unlock()
+	}
+}
+
+// PartPath returns the path where a file is assembled until it's complete.
+func PartPath(file string) string {
+	return file + ".part"
+}
+
+// Create creates an empty partial file, discarding any previous data.
+func Create(file string) error {
+	f, err := os.Create(PartPath(file))
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Offset returns the number of bytes received of a file.
+func Offset(file string) (int64, error) {
+	info, err := os.Stat(PartPath(file))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// WriteChunk appends a chunk read from r to the partial file of an upload of
+// length bytes. The chunk must start at offset, the number of bytes already
+// received. If sum isn't nil the chunk is only kept if its sha256 sum
+// matches. If reading the chunk fails the bytes read so far are kept. The new
+// offset is returned. The caller must hold the lock of the file.
+func WriteChunk(file string, offset, length int64, r io.Reader, sum []byte) (int64, error) {
+	f, err := os.OpenFile(PartPath(file), os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if info.Size() != offset {
+		return info.Size(), ErrOffsetMismatch
+	}
+
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return offset, err
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(r, length-offset+1))
+
+	switch {
+	case err == nil && n > length-offset:
+		err = ErrTooLarge
+	case err == nil && sum != nil && !bytes.Equal(sum, hash.Sum(nil)):
+		err = ErrChecksumMismatch
+	}
+
+	switch err {
+	case nil:
+	case ErrTooLarge, ErrChecksumMismatch:
+		// discard the invalid chunk such that the client can resend
+		// it from the same offset.
+		if terr := f.Truncate(offset); terr != nil {
+			return offset, terr
+		}
+		return offset, err
+	default:
+		// keep the bytes received before the chunk was interrupted,
+		// such that the client can resume after them.
+		if terr := f.Truncate(offset + n); terr != nil {
+			return offset, terr
+		}
+		return offset + n, err
+	}
+
+	return offset + n, f.Close()
+}
+
+// Complete verifies the sha256 sum of a fully received file and moves it in
+// place. The partial file is removed if the sum doesn't match.
+func Complete(file, sha256sum string) error {
+	part := PartPath(file)
+
+	f, err := os.Open(part)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != sha256sum {
+		os.Remove(part)
+		return fmt.Errorf("%w: expected sha256 %s, got %s", ErrChecksumMismatch, sha256sum, sum)
+	}
+
+	return os.Rename(part, file)
+}
@@ -0,0 +1,83 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test assembling a file from chunks.
+func TestWriteChunk(t *testing.T) {
+	data := "hello resumable world"
+	sum := sha256.Sum256([]byte(data))
+	file := path.Join(t.TempDir(), "foo-1.0-1-x86_64.pkg.tar.xz")
+
+	err := Create(file)
+	assert.NoError(t, err, "should not fail")
+
+	offset, err := WriteChunk(file, 0, int64(len(data)), strings.NewReader(data[:5]), nil)
+	assert.NoError(t, err, "should not fail")
+	assert.EqualValues(t, 5, offset, "should be equal")
+
+	// chunk not starting at the current offset.
+	offset, err = WriteChunk(file, 3, int64(len(data)), strings.NewReader(data[3:]), nil)
+	assert.Equal(t, ErrOffsetMismatch, err, "should be equal")
+	assert.EqualValues(t, 5, offset, "should be equal")
+
+	// chunk with a bad checksum is discarded.
+	bad := sha256.Sum256([]byte("bad"))
+	offset, err = WriteChunk(file, 5, int64(len(data)), strings.NewReader(data[5:10]), bad[:])
+	assert.Equal(t, ErrChecksumMismatch, err, "should be equal")
+	assert.EqualValues(t, 5, offset, "should be equal")
+
+	// chunk exceeding the upload length is discarded.
+	offset, err = WriteChunk(file, 5, int64(len(data)), strings.NewReader(data[5:]+"!"), nil)
+	assert.Equal(t, ErrTooLarge, err, "should be equal")
+	assert.EqualValues(t, 5, offset, "should be equal")
+
+	current, err := Offset(file)
+	assert.NoError(t, err, "should not fail")
+	assert.EqualValues(t, 5, current, "should be equal")
+
+	// the bytes of an interrupted chunk are kept.
+	interrupted := io.MultiReader(strings.NewReader(data[5:10]), iotest.ErrReader(errors.New("connection reset")))
+	offset, err = WriteChunk(file, 5, int64(len(data)), interrupted, nil)
+	assert.Error(t, err, "should fail")
+	assert.EqualValues(t, 10, offset, "should be equal")
+
+	current, err = Offset(file)
+	assert.NoError(t, err, "should not fail")
+	assert.EqualValues(t, 10, current, "should be equal")
+
+	chunk := sha256.Sum256([]byte(data[10:]))
+	offset, err = WriteChunk(file, 10, int64(len(data)), strings.NewReader(data[10:]), chunk[:])
+	assert.NoError(t, err, "should not fail")
+	assert.EqualValues(t, len(data), offset, "should be equal")
+
+	err = Complete(file, hex.EncodeToString(sum[:]))
+	assert.NoError(t, err, "should not fail")
+
+	content, err := os.ReadFile(file)
+	assert.NoError(t, err, "should not fail")
+	assert.Equal(t, data, string(content), "should be equal")
+
+	// complete file with the wrong checksum.
+	err = Create(file)
+	assert.NoError(t, err, "should not fail")
+	_, err = WriteChunk(file, 0, 3, strings.NewReader("bad"), nil)
+	assert.NoError(t, err, "should not fail")
+
+	err = Complete(file, hex.EncodeToString(sum[:]))
+	assert.ErrorIs(t, err, ErrChecksumMismatch, "should be equal")
+
+	_, err = os.Stat(PartPath(file))
+	assert.True(t, os.IsNotExist(err), "should be true")
+}